@@ -1,8 +1,9 @@
 package aparser
 
 import (
-	"os"
+	"atoken"
 	"fmt"
+	"math"
 	"math/big"
 )
 
@@ -213,46 +214,128 @@ func ToNumber(data string) *big.Float {
 //包装Number类型
 type AST_Number struct {
 	*big.Rat
+	isInt bool //缓存的Rat.IsInt()，Add/Sub/Mul/Div靠它判断能不能走big.Int的整数快路径，跳过有理数约分
+}
+
+//refreshIsInt在每次Rat被换成新值之后重新缓存一下isInt，Init/Add/Sub/Mul/Div/Mod/Pow
+//都要在改完s.Rat之后调一下这个，不然isInt会读到上一次运算留下的陈旧值
+func (s *AST_Number) refreshIsInt() {
+	s.isInt = s.Rat.IsInt()
 }
 
 //加
 func (s *AST_Number) Add(a *AST_Number, b *AST_Number) *AST_Number {
-	s.Rat.Add(a.Rat, b.Rat)
+	if a.isInt && b.isInt {
+		s.Rat = new(big.Rat).SetInt(new(big.Int).Add(a.Num(), b.Num()))
+	} else {
+		s.Rat.Add(a.Rat, b.Rat)
+	}
+	s.refreshIsInt()
 	return s
 }
 
 //减
 func (s *AST_Number) Sub(a *AST_Number, b *AST_Number) *AST_Number {
-	s.Rat.Sub(a.Rat, b.Rat)
+	if a.isInt && b.isInt {
+		s.Rat = new(big.Rat).SetInt(new(big.Int).Sub(a.Num(), b.Num()))
+	} else {
+		s.Rat.Sub(a.Rat, b.Rat)
+	}
+	s.refreshIsInt()
 	return s
 }
 
 //乘
 func (s *AST_Number) Mul(a *AST_Number, b *AST_Number) *AST_Number {
-	s.Rat.Mul(a.Rat, b.Rat)
+	if a.isInt && b.isInt {
+		s.Rat = new(big.Rat).SetInt(new(big.Int).Mul(a.Num(), b.Num()))
+	} else {
+		s.Rat.Mul(a.Rat, b.Rat)
+	}
+	s.refreshIsInt()
 	return s
 }
 
 //除
 func (s *AST_Number) Div(a *AST_Number, b *AST_Number) *AST_Number {
-	s.Rat.Quo(a.Rat, b.Rat)
+	if a.isInt && b.isInt {
+		s.Rat = new(big.Rat).SetFrac(a.Num(), b.Num())
+	} else {
+		s.Rat.Quo(a.Rat, b.Rat)
+	}
+	s.refreshIsInt()
 	return s
 }
 
 //绝对值
 func (s *AST_Number) Abs(a *AST_Number, b *AST_Number) *AST_Number {
 	s.Rat.Abs(a.Rat)
+	s.refreshIsInt()
 	return s
 }
 
+//Mod把a/b对c/d取模：分别取出两边的分子分母，通分到b*d上之后用big.Int.Mod
+//对(a*d)和(b*c)求欧几里得余数，最后把结果约分回去。big.Int.Mod的结果不论
+//两边的符号如何总是非负(0 <= 结果 < |c/d|)，这里沿用它的语义，不额外处理符号
 func (s *AST_Number) Mod(a *AST_Number, b *AST_Number) *AST_Number {
+	numA, denA := a.Num(), a.Denom()
+	numB, denB := b.Num(), b.Denom()
+
+	var scaledA, scaledB, remainder, commonDenom big.Int
+	scaledA.Mul(numA, denB)
+	scaledB.Mul(denA, numB)
+	remainder.Mod(&scaledA, &scaledB)
+	commonDenom.Mul(denA, denB)
+
+	s.Rat = new(big.Rat).SetFrac(&remainder, &commonDenom)
+	s.refreshIsInt()
+	return s
+}
+
+//PowPrecision是Pow在指数不是整数、要退化到big.Float求值时用的精度(单位:bit)
+var PowPrecision uint = 256
+
+//Pow计算base的exp次方。exp是有理数意义上的整数(分母是1，哪怕它本身是
+//"4/1"这种形式)时，分子分母各自用big.Int.Exp求幂再拼回分数，结果仍然精确；
+//负整数次方在拼分数前把分子分母互换。exp不是整数时退化成先转成float64算出
+//近似值，再按PowPrecision包回big.Float——这条路径的精度因此仍然被float64
+//的53位尾数卡住，math/big没有现成的超越函数可用，这里只是保持接口的形状
+func (s *AST_Number) Pow(base *AST_Number, exp *AST_Number) *AST_Number {
+	if exp.Rat.IsInt() {
+		e := exp.Num()
+		absExp := new(big.Int).Abs(e)
+
+		var numPow, denPow big.Int
+		numPow.Exp(base.Num(), absExp, nil)
+		denPow.Exp(base.Denom(), absExp, nil)
+
+		if e.Sign() < 0 {
+			s.Rat = new(big.Rat).SetFrac(&denPow, &numPow)
+		} else {
+			s.Rat = new(big.Rat).SetFrac(&numPow, &denPow)
+		}
+		s.refreshIsInt()
+		return s
+	}
 
+	baseF, _ := base.Rat.Float64()
+	expF, _ := exp.Rat.Float64()
+	var result *big.Rat
+	if f := math.Pow(baseF, expF); !math.IsNaN(f) { //SetFloat64遇到NaN(比如负数的非整数次方)会panic
+		result, _ = new(big.Float).SetPrec(PowPrecision).SetFloat64(f).Rat(nil)
+	}
+	if result == nil {
+		result = new(big.Rat) //Inf/NaN(比如0的负数次方)时退化成0，而不是把nil Rat往下传
+	}
+	s.Rat = result
+	s.refreshIsInt()
 	return s
 }
 
 func (s *AST_Number) Init(data string) *AST_Number {
 	s.Rat = big.NewRat(0, 1)
 	s.Rat.SetString(data)
+	s.refreshIsInt()
 	return s
 }
 
@@ -264,96 +347,107 @@ func New_ASTNumber(data string) *AST_Number {
 type AParser_Token struct {
 }
 
-type AST_Arithmetic_Expression struct {
-	Type int
-	Value_Term *AST_Arithmetic_Expression_Term
-	Value_Exp *AST_Arithmetic_Expression
+//Span标记AST节点对应的源码区间，供诊断信息在Diagnose里画出精确的caret
+type Span struct {
+	File      string
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
 }
 
-func (s *AST_Arithmetic_Expression) CheckType() int{
-	if s.Type == 0 && s.Value_Exp == nil{
-		return s.Value_Term.CheckType()
-	}
-	
-	Type1 := s.Value_Term.CheckType()
-	Type2 := s.Value_Exp.CheckType()
-	
-	if (Type1 == BOOL && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == BOOL){
-		fmt.Println("error:number不可以和bool运算")
-		os.Exit(1)
+//mergeSpan把两个先后相邻的Span合并成覆盖两者的最小区间，
+//用于各种表达式节点把各自操作数的Span拼成整个节点的Span
+func mergeSpan(first Span, last Span) Span {
+	return Span{
+		File:      first.File,
+		StartLine: first.StartLine,
+		StartCol:  first.StartCol,
+		EndLine:   last.EndLine,
+		EndCol:    last.EndCol,
 	}
-	
-	if (Type1 == STRING && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == STRING){
-		fmt.Println("error:number不可以和string运算")
-		os.Exit(1)
-	}
-	
-	if (Type1 == CHAR && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == CHAR){
-		fmt.Println("error:number不可以和char运算")
-		os.Exit(1)
-	}
-	
-	//暂时不实现操作符重载
-	return Type1
 }
 
-func (s *AST_Arithmetic_Expression) Show(){
-	if s.Type == 0 && s.Value_Exp == nil{
-		s.Value_Term.Show()
-		return
+//typeClashError在Type1/Type2之间存在不允许混用的情况时构造一条*AParserError，
+//否则返回nil——被Arithmetic_Expression和Comparison_Expression的CheckType共用，避免同一组三连if重复两遍
+func typeClashError(span Span, Type1 int, Type2 int) *AParserError {
+	var message string
+	switch {
+	case (Type1 == BOOL && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == BOOL):
+		message = "error:number不可以和bool运算"
+	case (Type1 == STRING && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == STRING):
+		message = "error:number不可以和string运算"
+	case (Type1 == CHAR && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == CHAR):
+		message = "error:number不可以和char运算"
+	default:
+		return nil
 	}
-	
-	fmt.Println("Symbol:",s.Type)
-	
-	s.Value_Term.Show()	
-	s.Value_Exp.Show()
-
-	
+	return &AParserError{span.StartLine, span.StartCol, span.File, message}
 }
 
-type AST_Arithmetic_Expression_Term struct {
-	Type int
-	Value_Term  *AST_Arithmetic_Expression_Term
-	Value_Factor *AST_Arithmetic_Expression_Factor
+//AST_Arithmetic_Expression是precedence-climbing(见aparser_tree.go的parseExpr)
+//产出的一棵二叉树：Op==0表示这是一个叶子节点，值在Factor里；否则Left/Right是
+//两棵子树，Op是ADD/SUB/MUL/.../POWER之一。比起之前Expression/_Term两层手写
+//递归各管一种优先级，现在所有算术运算符的优先级都收拢进operatorPriority这
+//一张表，新增运算符不需要再多一层产生式
+type AST_Arithmetic_Expression struct {
+	Op     int
+	Left   *AST_Arithmetic_Expression
+	Right  *AST_Arithmetic_Expression
+	Factor *AST_Arithmetic_Expression_Factor //Op==0时的叶子值
+	Trivia []atoken.TriviaToken              //这个节点对应的运算符之前挂着的free-floating trivia
+
+	Call     *AST_CallFuncStatement //CheckType撞上一条重载绑定之后，这个节点被改写成调用的函数；Left/Right仍然保留原始操作数
+	registry *OperatorRegistry      //parseExpr构造这个节点时记下的重载表，供CheckType查重载
 }
 
-//生成字节码
-func (s *AST_Arithmetic_Expression_Term) CheckType() int{
-	if s.Type == 0 && s.Value_Term == nil{
-		return s.Value_Factor.CheckType()
+//Span返回这个节点覆盖的源码区间，叶子节点就是Factor自己的区间
+func (s *AST_Arithmetic_Expression) Span() Span {
+	if s.Op == 0 {
+		return s.Factor.Span
 	}
-	
-	Type1 := s.Value_Factor.CheckType()
-	Type2 := s.Value_Term.CheckType()
-	
-	if (Type1 == BOOL && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == BOOL){
-		fmt.Println("error:number不可以和bool运算")
-		os.Exit(1)
+	return mergeSpan(s.Left.Span(), s.Right.Span())
+}
+
+//CheckType不再在第一个类型错误上os.Exit(1)，而是把*AParserError一路累积着
+//往上传，这样一次编译单元里的类型错误可以一次性全部报给调用方
+func (s *AST_Arithmetic_Expression) CheckType() (int, []*AParserError) {
+	if s.Op == 0 {
+		return s.Factor.CheckType()
 	}
-	
-	if (Type1 == STRING && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == STRING){
-		fmt.Println("error:number不可以和string运算")
-		os.Exit(1)
+
+	Type1, errs1 := s.Left.CheckType()
+	Type2, errs2 := s.Right.CheckType()
+	errs := append(errs1, errs2...)
+
+	if binding := s.registry.Lookup(s.Op, Type1, Type2); binding != nil {
+		//binding.CallTarget是registry里共享的原型，只携带函数名；Args要按这
+		//个节点自己的Left/Right现造一份，不能直接拿来用，否则两个撞上同一个
+		//重载的不同节点会共享同一个*AST_CallFuncStatement，互相篡改对方的实参
+		s.Call = &AST_CallFuncStatement{
+			Name: binding.CallTarget.Name,
+			Args: []*AST_Arithmetic_Expression{s.Left, s.Right},
+		}
+		return binding.ResultType, errs
 	}
-	
-	if (Type1 == CHAR && Type2 == NUMBER) || (Type1 == NUMBER && Type2 == CHAR){
-		fmt.Println("error:number不可以和char运算")
-		os.Exit(1)
+
+	if err := typeClashError(s.Span(), Type1, Type2); err != nil {
+		errs = append(errs, err)
 	}
-	
-	//暂时不实现操作符重载
-	return Type1
+
+	return Type1, errs
 }
 
-func (s *AST_Arithmetic_Expression_Term) Show(){
-	if s.Type == 0 && s.Value_Term == nil{
-		s.Value_Factor.Show()
+func (s *AST_Arithmetic_Expression) Show() {
+	if s.Op == 0 {
+		s.Factor.Show()
 		return
 	}
-	
-	fmt.Println("Symbol:",s.Type)
-	s.Value_Factor.Show()
-	s.Value_Term.Show()	
+
+	fmt.Println("Symbol:", s.Op)
+
+	s.Left.Show()
+	s.Right.Show()
 }
 
 type AST_Arithmetic_Expression_Factor struct {
@@ -363,8 +457,10 @@ type AST_Arithmetic_Expression_Factor struct {
 	Value_Bool                  bool                       //布尔型
 	Value_CallFunc              *AST_CallFuncStatement     //调用函数
 	Value_Arithmetic_Expression *AST_Arithmetic_Expression //基本表达式
+	Value_String                *AST_StringLiteral         //字符串字面量(含heredoc)
 	Value_VarWord               string
-	Line                        int //行
+	Span                        Span                 //这个factor覆盖的源码区间，供Diagnose画caret用
+	Trivia                      []atoken.TriviaToken //这个factor起始token前面挂着的free-floating trivia
 }
 
 func (s *AST_Arithmetic_Expression_Factor) Show(){
@@ -372,24 +468,371 @@ func (s *AST_Arithmetic_Expression_Factor) Show(){
 	if s.Type == NUMBER{
 		fmt.Printf("%s\n",s.Value_Number.FloatString(3))
 	}
-	
+
 	if s.Type == ARITHMETICEXPRESSION{
 		s.Value_Arithmetic_Expression.Show()
 	}
-	
+
 	if s.Type == BOOL{
-		fmt.Println(s.Value_Bool)	
+		fmt.Println(s.Value_Bool)
+	}
+
+	if s.Type == STRING{
+		fmt.Println(s.Value_String)
 	}
 }
 
-func (s *AST_Arithmetic_Expression_Factor) CheckType() int{
-	if s.Type == ARITHMETICEXPRESSION{
+func (s *AST_Arithmetic_Expression_Factor) CheckType() (int, []*AParserError) {
+	if s.Type == ARITHMETICEXPRESSION {
 		return s.Value_Arithmetic_Expression.CheckType()
 	}
-	
-	return s.Type
+
+	return s.Type, nil
+}
+
+//AST_Comparison_Expression对应两个算术表达式之间最多一次的比较，比如a <= b。
+//和+/-不同，比较运算符不支持链式结合，所以Type==0时就是退化成单独的Left，
+//否则就是Left和Right之间的一次比较，不会再往下嵌套
+type AST_Comparison_Expression struct {
+	Type   int //0表示没有比较运算符，否则是LT/LTE/GT/GTE/EQ/NEQ之一
+	Left   *AST_Arithmetic_Expression
+	Right  *AST_Arithmetic_Expression
+	Trivia []atoken.TriviaToken //运算符之前挂着的free-floating trivia
+}
+
+func (s *AST_Comparison_Expression) Span() Span {
+	if s.Type == 0 {
+		return s.Left.Span()
+	}
+	return mergeSpan(s.Left.Span(), s.Right.Span())
+}
+
+//CheckType对两边做和Arithmetic_Expression一样的类型冲突检查，但结果永远是BOOL
+func (s *AST_Comparison_Expression) CheckType() (int, []*AParserError) {
+	Type1, errs := s.Left.CheckType()
+	if s.Type == 0 {
+		return Type1, errs
+	}
+
+	Type2, errs2 := s.Right.CheckType()
+	errs = append(errs, errs2...)
+	if err := typeClashError(s.Span(), Type1, Type2); err != nil {
+		errs = append(errs, err)
+	}
+
+	return BOOL, errs
+}
+
+func (s *AST_Comparison_Expression) Show() {
+	s.Left.Show()
+	if s.Type == 0 {
+		return
+	}
+	fmt.Println("Symbol:", s.Type)
+	s.Right.Show()
+}
+
+//AST_Logical_Expression对应&&/||链起来的一串操作数，每个操作数前面还能挂一个
+//一元的!，本身要么是一次Comparison_Expression，要么是带括号的分组
+//'(' Logical_Expression ')'(由IsGroup/Group选择)。链条里的节点通过
+//Value_Exp依次串起来，Type记录它和下一个节点之间的运算符
+type AST_Logical_Expression struct {
+	Type      int                        //0，或者LAND/LOR：这个节点和下一个节点之间的结合方式
+	Not       bool                       //这个节点的操作数前面有没有一元的!
+	IsGroup   bool                       //操作数是不是一个带括号的Logical_Expression分组
+	Value_Cmp *AST_Comparison_Expression //IsGroup==false时的操作数
+	Group     *AST_Logical_Expression    //IsGroup==true时的操作数
+	Value_Exp *AST_Logical_Expression    //链条的下一环
+	Trivia    []atoken.TriviaToken       //运算符之前挂着的free-floating trivia
+}
+
+func (s *AST_Logical_Expression) operandSpan() Span {
+	if s.IsGroup {
+		return s.Group.Span()
+	}
+	return s.Value_Cmp.Span()
+}
+
+func (s *AST_Logical_Expression) Span() Span {
+	if s.Type == 0 && s.Value_Exp == nil {
+		return s.operandSpan()
+	}
+	return mergeSpan(s.operandSpan(), s.Value_Exp.Span())
+}
+
+func (s *AST_Logical_Expression) operandType() (int, []*AParserError) {
+	if s.IsGroup {
+		return s.Group.CheckType()
+	}
+	return s.Value_Cmp.CheckType()
+}
+
+//CheckType要求两边都必须是BOOL，否则累积一条错误；结果永远是BOOL
+func (s *AST_Logical_Expression) CheckType() (int, []*AParserError) {
+	Type1, errs := s.operandType()
+	if s.Type == 0 && s.Value_Exp == nil {
+		return BOOL, errs
+	}
+
+	//链条最后一环的CheckType不检查自己的操作数，所以它的类型在这里直接取
+	var Type2 int
+	var errs2 []*AParserError
+	if s.Value_Exp.Type == 0 && s.Value_Exp.Value_Exp == nil {
+		Type2, errs2 = s.Value_Exp.operandType()
+	} else {
+		Type2, errs2 = s.Value_Exp.CheckType()
+	}
+	errs = append(errs, errs2...)
+	if Type1 != BOOL || Type2 != BOOL {
+		span := s.Span()
+		errs = append(errs, &AParserError{span.StartLine, span.StartCol, span.File, "error:&&和||只能用于bool表达式"})
+	}
+
+	return BOOL, errs
+}
+
+func (s *AST_Logical_Expression) Show() {
+	if s.Not {
+		fmt.Println("Symbol:", LNOT)
+	}
+	if s.IsGroup {
+		s.Group.Show()
+	} else {
+		s.Value_Cmp.Show()
+	}
+	if s.Type == 0 && s.Value_Exp == nil {
+		return
+	}
+	fmt.Println("Symbol:", s.Type)
+	s.Value_Exp.Show()
+}
+
+//AST_Conditional_Expression对应三元表达式 cond ? TrueExpr : FalseExpr，
+//是目前表达式里结合优先级最低的一层——先判断CondExpr，再在两个分支之间选
+type AST_Conditional_Expression struct {
+	CondExpr  *AST_Logical_Expression
+	TrueExpr  *AST_Arithmetic_Expression
+	FalseExpr *AST_Arithmetic_Expression
+	Trivia    []atoken.TriviaToken //'?'之前挂着的free-floating trivia
+}
+
+func (s *AST_Conditional_Expression) Span() Span {
+	if s.TrueExpr == nil && s.FalseExpr == nil {
+		return s.CondExpr.Span()
+	}
+	return mergeSpan(s.CondExpr.Span(), s.FalseExpr.Span())
+}
+
+//CheckType要求两个分支的类型必须一致，否则累积一条错误；结果是分支的类型
+func (s *AST_Conditional_Expression) CheckType() (int, []*AParserError) {
+	_, condErrs := s.CondExpr.CheckType()
+	if s.TrueExpr == nil && s.FalseExpr == nil {
+		return BOOL, condErrs
+	}
+
+	Type1, errs1 := s.TrueExpr.CheckType()
+	Type2, errs2 := s.FalseExpr.CheckType()
+	errs := append(condErrs, append(errs1, errs2...)...)
+	if Type1 != Type2 {
+		span := s.Span()
+		errs = append(errs, &AParserError{span.StartLine, span.StartCol, span.File, "error:三元表达式的两个分支类型必须一致"})
+	}
+
+	return Type1, errs
+}
+
+func (s *AST_Conditional_Expression) Show() {
+	s.CondExpr.Show()
+	if s.TrueExpr == nil && s.FalseExpr == nil {
+		return
+	}
+	fmt.Println("Symbol:", "?:")
+	s.TrueExpr.Show()
+	s.FalseExpr.Show()
 }
 
 type AST_CallFuncStatement struct {
-	ReturnValueList *AST_Arithmetic_Expression
+	Name string                       //被调用的函数名
+	Args []*AST_Arithmetic_Expression //实参列表
+}
+
+//StringPart是字符串字面量里的一段，按Kind区分是原样文本还是插值
+const (
+	StringPartRaw = iota //普通文本
+	StringPartVarRef      //$name 形式的变量插值
+	StringPartExpr        //${expr} 形式的表达式插值
+)
+
+type StringPart struct {
+	Kind   int
+	Raw    string                     //Kind==StringPartRaw时的原样文本
+	VarRef string                     //Kind==StringPartVarRef时引用的变量名
+	Expr   *AST_Arithmetic_Expression //Kind==StringPartExpr时插值的表达式
+}
+
+//AST_StringLiteral是"..."/<<<LBL ... LBL字符串字面量解析出的有序片段列表，
+//普通文本和$name/${expr}插值按出现顺序排列
+type AST_StringLiteral struct {
+	Parts []StringPart
+}
+
+//isIdentRune和judge_symbol在atoken里的定义保持一致，判断一个字符能不能
+//出现在$name这种裸变量插值里
+func isIdentRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
+}
+
+//parseStringLiteral把词法阶段交回的原始字符串内容切分成Raw/VarRef/Expr
+//片段。$name插值只消费标识符字符；${expr}插值把大括号内的文本交给一个
+//独立的AParser当成基本表达式解析，这样插值表达式可以用上已有的
+//Arithmetic_Expression文法，不需要另写一套小解析器。
+func (s *AParser) parseStringLiteral(raw string) *AST_StringLiteral {
+	result := new(AST_StringLiteral)
+	runes := []rune(raw)
+	var pending []rune
+
+	flush := func() {
+		if len(pending) > 0 {
+			result.Parts = append(result.Parts, StringPart{Kind: StringPartRaw, Raw: string(pending)})
+			pending = nil
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		if runes[i] != '$' || i+1 >= len(runes) {
+			pending = append(pending, runes[i])
+			i++
+			continue
+		}
+
+		if runes[i+1] == '{' {
+			flush()
+			depth := 1
+			j := i + 2
+			for j < len(runes) && depth > 0 {
+				if runes[j] == '{' {
+					depth++
+				} else if runes[j] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+				j++
+			}
+			exprText := string(runes[i+2 : j])
+			result.Parts = append(result.Parts, StringPart{Kind: StringPartExpr, Expr: New().ReadBasicExpression(exprText)})
+			if j < len(runes) {
+				j++ //跳过结尾的'}'
+			}
+			i = j
+			continue
+		}
+
+		if isIdentRune(runes[i+1]) {
+			flush()
+			j := i + 1
+			for j < len(runes) && isIdentRune(runes[j]) {
+				j++
+			}
+			result.Parts = append(result.Parts, StringPart{Kind: StringPartVarRef, VarRef: string(runes[i+1 : j])})
+			i = j
+			continue
+		}
+
+		pending = append(pending, runes[i])
+		i++
+	}
+	flush()
+	return result
+}
+
+//语句种类，供AST_Statement.Kind标记到底挂的是哪个具体字段
+const (
+	STMT_FUNC = iota + 1
+	STMT_VAR
+	STMT_ASSIGN
+	STMT_BLOCK
+	STMT_CALL
+	STMT_FOR
+	STMT_IF
+	STMT_RETURN
+	STMT_BREAK
+	STMT_CONTINUE
+)
+
+//AST_Statement是所有语句产生式的统一包装。在此之前只有Arithmetic_Expression
+//一家产生真正的AST，Statement/FuncStatement/IFStatement/ForStatement等都只是
+//解析完就丢掉结果；现在它们把自己的结果挂到这里，供aprinter之类的下游工具遍历。
+type AST_Statement struct {
+	Kind     int
+	Func     *AST_FuncStatement
+	Var      *AST_VarStatement
+	Assign   *AST_AssigmentStatement
+	Block    *AST_Block
+	Call     *AST_CallFuncStatement
+	For      *AST_ForStatement
+	If       *AST_IfStatement
+	Return   *AST_ReturnStatement
+	Break    bool
+	Continue bool
+}
+
+//AST_Block是一对大括号里按顺序排列的语句列表
+type AST_Block struct {
+	Statements []*AST_Statement
+}
+
+//DeclModifiers记录挂在一条func/var声明前面的修饰符(static/extern/ghost)，
+//以及每个修饰符对应的token，供诊断定位具体的冲突位置。
+type DeclModifiers struct {
+	IsStatic bool
+	IsExtern bool
+	IsGhost  bool
+
+	StaticToken *atoken.AToken
+	ExternToken *atoken.AToken
+	GhostToken  *atoken.AToken
+}
+
+//AST_FuncStatement对应 (修饰符...) func 名字(参数...) -> 返回类型 {...}
+type AST_FuncStatement struct {
+	Name      string
+	Body      *AST_Block
+	Modifiers DeclModifiers
+}
+
+//AST_VarStatement对应 (修饰符...) var 名字 -> 类型 或者 (修饰符...) var 名字 = 表达式
+type AST_VarStatement struct {
+	Name      string
+	Assign    *AST_AssigmentStatement //等号右边的赋值，var x -> 类型 这种形式下为nil
+	Modifiers DeclModifiers
+}
+
+//AST_AssigmentStatement对应 名字(,名字)* = 表达式(,表达式)*
+type AST_AssigmentStatement struct {
+	Names  []string
+	Values []*AST_Arithmetic_Expression
+}
+
+//AST_IfStatement对应 if(...){...}，后面最多跟一个elseif(...){...}或者一个else{...}：
+//解析器不会在elseif块之后继续解析elseif/else，所以ElseIf节点自己的ElseIf/Else总是nil
+type AST_IfStatement struct {
+	Cond   *AST_Logical_Expression
+	Then   *AST_Block
+	ElseIf *AST_IfStatement
+	Else   *AST_Block
+}
+
+//AST_ForStatement对应 for(初始化;条件;步进){...}
+type AST_ForStatement struct {
+	Init *AST_Statement
+	Cond *AST_Logical_Expression //为空表示条件被省略，相当于恒真
+	Post *AST_Statement
+	Body *AST_Block
+}
+
+//AST_ReturnStatement对应 return 表达式(,表达式)*
+type AST_ReturnStatement struct {
+	Values []*AST_Arithmetic_Expression
 }
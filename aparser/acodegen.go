@@ -0,0 +1,70 @@
+package aparser
+
+import "fmt"
+
+//CodeEmitter是Emit方法的输出目标。不同的后端(栈式字节码、文本反汇编，
+//以后也许是LLVM或者Go源码)各自实现这六个方法就行，aparser本身不关心
+//消费者具体拿指令去做什么——这样第三方可以插自己的后端而不用改解析器
+type CodeEmitter interface {
+	EmitConst(v *AST_Number)
+	EmitBinOp(op int)
+	EmitCall(name string, argc int)
+	EmitLoad(name string)
+	EmitJumpIfFalse(label string)
+	EmitLabel(label string)
+}
+
+//Emit把这棵算术表达式树按后序遍历喂给e：叶子节点之外，先喂左子树再喂右
+//子树，最后喂运算符本身（或者CheckType改写出来的调用，见下），这正好是
+//栈式虚拟机想要的指令顺序——两个操作数先后压栈，再碰到BinOp/Call指令时
+//栈顶已经是它们俩了
+func (s *AST_Arithmetic_Expression) Emit(e CodeEmitter) {
+	if s.Op == 0 {
+		s.Factor.Emit(e)
+		return
+	}
+
+	s.Left.Emit(e)
+	s.Right.Emit(e)
+	if s.Call != nil {
+		//CheckType命中过重载绑定，这个节点实际上已经被改写成一次函数调用了
+		//(比如STRING+STRING变成string_concat)，要喂Call指令而不是裸BinOp，
+		//不然字节码还是按原始运算符执行，跟类型检查时的改写对不上
+		e.EmitCall(s.Call.Name, len(s.Call.Args))
+		return
+	}
+	e.EmitBinOp(s.Op)
+}
+
+//Emit只覆盖了当前会出现在算术表达式里的几种Factor：数字/布尔字面量常量化，
+//变量读取成Load，括号子表达式直接递归，函数调用先喂参数再喂Call。字符串/
+//字符字面量和自增自减还没有对应的指令，真撞上就直接panic而不是悄悄产出
+//错误的字节码
+func (s *AST_Arithmetic_Expression_Factor) Emit(e CodeEmitter) {
+	switch s.Type {
+	case NUMBER:
+		e.EmitConst(s.Value_Number)
+	case BOOL:
+		e.EmitConst(boolNumber(s.Value_Bool))
+	case VAR:
+		e.EmitLoad(s.Value_VarWord)
+	case ARITHMETICEXPRESSION:
+		s.Value_Arithmetic_Expression.Emit(e)
+	case CALLFUNC:
+		for _, arg := range s.Value_CallFunc.Args {
+			arg.Emit(e)
+		}
+		e.EmitCall(s.Value_CallFunc.Name, len(s.Value_CallFunc.Args))
+	default:
+		panic(fmt.Sprintf("aparser: Emit does not support factor type %d yet", s.Type))
+	}
+}
+
+//boolNumber把一个bool包成AST_Number，这样EmitConst一个接口方法就能同时
+//承载数字和布尔字面量——true记成1，false记成0
+func boolNumber(b bool) *AST_Number {
+	if b {
+		return New_ASTNumber("1")
+	}
+	return New_ASTNumber("0")
+}
@@ -0,0 +1,89 @@
+package aparser
+
+import (
+	"atoken"
+	"fmt"
+)
+
+//Diagnostic描述一次语法不匹配：期望的token集合、实际读到的token，以及产生式路径
+type Diagnostic struct {
+	File       string
+	Line       int
+	Column     int
+	Expected   []string
+	Got        string
+	Production string
+}
+
+func (s *Diagnostic) Error() string {
+	if s.Got == "" && len(s.Expected) == 0 {
+		//转接自PushError的诊断，Production里已经是完整的错误信息
+		return fmt.Sprintf("Error(Parser)%s[Line:%d,Column:%d]:%s", s.File, s.Line, s.Column, s.Production)
+	}
+	return fmt.Sprintf("Error(Parser)%s[Line:%d,Column:%d]: unexpected %s expecting %s ->%s",
+		s.File, s.Line, s.Column, s.Got, joinExpected(s.Expected), s.Production)
+}
+
+func joinExpected(expected []string) string {
+	if len(expected) == 0 {
+		return "<nothing>"
+	}
+	result := expected[0]
+	for _, e := range expected[1:] {
+		result += " || " + e
+	}
+	return result
+}
+
+//DiagnosticSink累积解析过程中产生的所有诊断信息，不再在第一个错误处中断解析
+type DiagnosticSink struct {
+	diagnostics []*Diagnostic
+}
+
+func (s *DiagnosticSink) Add(d *Diagnostic) {
+	s.diagnostics = append(s.diagnostics, d)
+}
+
+func (s *DiagnosticSink) Len() int {
+	return len(s.diagnostics)
+}
+
+func (s *DiagnosticSink) All() []*Diagnostic {
+	return s.diagnostics
+}
+
+//PushDiagnostic记录一条诊断并返回它，供调用方在需要时进入panic-mode恢复
+func (s *AParser) PushDiagnostic(line int, column int, production string, got string, expected ...string) *Diagnostic {
+	d := &Diagnostic{
+		File:       s.File,
+		Line:       line,
+		Column:     column,
+		Expected:   expected,
+		Got:        got,
+		Production: production,
+	}
+	s.Diagnostics.Add(d)
+	return d
+}
+
+//Synchronize实现panic-mode错误恢复：从当前位置向后跳过token，
+//直到遇到语句的同步点（顶层关键字、'}'或EOF），再把光标还给调用方恢复解析。
+//这样一次不匹配只丢弃一条语句，而不是中止整个解析过程。
+func (s *AParser) Synchronize() {
+	for {
+		if s.AToken.IsEnd() {
+			return
+		}
+		token := s.AToken.GetToken()
+		if token == nil {
+			return
+		}
+		switch token.Type {
+		case atoken.FUNC, atoken.VAR, atoken.FOR, atoken.IF, atoken.RETURN:
+			s.AToken.BackToken()
+			return
+		case atoken.RBRACE, atoken.EOF:
+			return
+		}
+	}
+}
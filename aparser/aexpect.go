@@ -0,0 +1,68 @@
+package aparser
+
+import (
+	"atoken"
+	"sort"
+)
+
+//expect消费下一个token并要求其类型与tokenType一致。无论匹配与否都会把
+//这个位置"期望"的类型记进expectedSet，这样在解析结束后可以从parser真正
+//到达过的最远位置合成一条精确的诊断信息，而不是依赖每个产生式各写一遍
+//错误字符串。匹配失败时仍然像过去一样PushError+Synchronize，行为不变。
+func (s *AParser) expect(tokenType int, production string) (*atoken.AToken, bool) {
+	token := s.AToken.GetToken()
+	s.recordExpected(token, tokenType)
+	if token.Type == tokenType {
+		return token, true
+	}
+	s.PushError(token.Line, token.Column, s.File, "unexpected "+token.Value+" expecting '"+atoken.Name(tokenType)+"' ->"+production)
+	s.Synchronize()
+	return token, false
+}
+
+//recordExpected维护farthest游标：position用token的(Line,Column)编码，
+//每次前进到一个此前没有到达过的位置时，就把farthestToken更新过去。
+func (s *AParser) recordExpected(token *atoken.AToken, tokenType int) {
+	if token == nil {
+		return
+	}
+	pos := token.Line*1<<20 + token.Column
+
+	if s.expectedSet == nil {
+		s.expectedSet = make(map[int]map[int]bool)
+	}
+	if s.expectedSet[pos] == nil {
+		s.expectedSet[pos] = make(map[int]bool)
+	}
+	s.expectedSet[pos][tokenType] = true
+
+	if pos > s.farthest || s.farthestToken == nil {
+		s.farthest = pos
+		s.farthestToken = token
+	}
+}
+
+//FinalDiagnostic把farthest位置上积累的expectedSet合成为一条诊断信息，
+//代表"parser实际卡住的地方"，而不是它在回溯过程中经过的中间错误。
+//没有任何错误发生时返回nil。
+func (s *AParser) FinalDiagnostic() *Diagnostic {
+	if !s.hadError || s.farthestToken == nil {
+		return nil
+	}
+
+	types := s.expectedSet[s.farthest]
+	expected := make([]string, 0, len(types))
+	for t := range types {
+		expected = append(expected, atoken.Name(t))
+	}
+	sort.Strings(expected)
+
+	return &Diagnostic{
+		File:       s.File,
+		Line:       s.farthestToken.Line,
+		Column:     s.farthestToken.Column,
+		Got:        s.farthestToken.Value,
+		Expected:   expected,
+		Production: "farthest reached",
+	}
+}
@@ -0,0 +1,237 @@
+package aparser
+
+import "math/big"
+
+//literalNumber返回e是不是一个NUMBER字面量叶子节点，是的话顺带给出它的值。
+//Fold只对字面量叶子做常量求值，带变量/函数调用的子树原样保留
+func literalNumber(e *AST_Arithmetic_Expression) (*AST_Number, bool) {
+	if e.Op != 0 || e.Factor.Type != NUMBER {
+		return nil, false
+	}
+	return e.Factor.Value_Number, true
+}
+
+//isNumberLiteral判断e是不是值恰好等于want的NUMBER字面量，供x+0/x*1这类
+//代数化简识别中性元
+func isNumberLiteral(e *AST_Arithmetic_Expression, want int64) bool {
+	n, ok := literalNumber(e)
+	if !ok {
+		return false
+	}
+	return n.Cmp(big.NewRat(want, 1)) == 0
+}
+
+//numberLiteralExpr包出一个Op==0的叶子节点，值是value，Span沿用原节点的
+//Span方便诊断信息还能指到原来的位置
+func numberLiteralExpr(value *AST_Number, span Span) *AST_Arithmetic_Expression {
+	return &AST_Arithmetic_Expression{
+		Factor: &AST_Arithmetic_Expression_Factor{Type: NUMBER, Value_Number: value, Span: span},
+	}
+}
+
+//sameOperand判断两个节点是不是"同一个变量"，用来识别x-x这种能直接折成0的
+//写法。只认VAR是因为其它Factor类型(函数调用、自增自减)都带副作用，折叠掉
+//会改变语义，不能只看值是否"看起来一样"
+func sameOperand(a, b *AST_Arithmetic_Expression) bool {
+	if a.Op != 0 || b.Op != 0 {
+		return false
+	}
+	if a.Factor.Type != VAR || b.Factor.Type != VAR {
+		return false
+	}
+	return a.Factor.Value_VarWord == b.Factor.Value_VarWord
+}
+
+//hasSideEffects判断e的子树里有没有函数调用或者自增自减，有的话折叠掉整棵
+//子树就会丢掉这个副作用，改变语义，不能只图值"看起来是0/1"就直接扔掉
+func hasSideEffects(e *AST_Arithmetic_Expression) bool {
+	if e.Op != 0 {
+		return hasSideEffects(e.Left) || hasSideEffects(e.Right)
+	}
+	switch e.Factor.Type {
+	case CALLFUNC, SELFOPERATION_ADDSELF, SELFOPERATION_SUBSELF:
+		return true
+	case ARITHMETICEXPRESSION:
+		return hasSideEffects(e.Factor.Value_Arithmetic_Expression)
+	}
+	return false
+}
+
+//Fold对算术表达式树做自底向上的常量折叠和代数化简，要求已经先跑过一次
+//CheckType确认过类型。两侧都是NUMBER字面量的子树会被直接求值替换掉；
+//x+0/x*1/x*0/x-x/x/1这类恒等式即使不是全字面量也会被化简。返回折叠后的
+//根节点，调用方应该用返回值替换掉原来持有的指针
+func (s *AST_Arithmetic_Expression) Fold() *AST_Arithmetic_Expression {
+	if s.Op == 0 {
+		s.Factor = s.Factor.Fold()
+		return s
+	}
+
+	s.Left = s.Left.Fold()
+	s.Right = s.Right.Fold()
+
+	if folded := s.foldConstants(); folded != nil {
+		return folded
+	}
+	if folded := s.foldIdentities(); folded != nil {
+		return folded
+	}
+	return s
+}
+
+//foldConstants在Left/Right都是NUMBER字面量时用AST_Number现成的
+//Add/Sub/Mul/Div/Mod/Pow直接求值。RAND不在这里折叠——它每次求值都要重新
+//掷一次随机数，根本不是常量，折叠掉就改变了程序的行为
+func (s *AST_Arithmetic_Expression) foldConstants() *AST_Arithmetic_Expression {
+	left, ok := literalNumber(s.Left)
+	if !ok {
+		return nil
+	}
+	right, ok := literalNumber(s.Right)
+	if !ok {
+		return nil
+	}
+
+	result := &AST_Number{Rat: new(big.Rat)}
+	switch s.Op {
+	case ADD:
+		result.Add(left, right)
+	case SUB:
+		result.Sub(left, right)
+	case MUL:
+		result.Mul(left, right)
+	case DIV:
+		if right.Sign() == 0 {
+			return nil //除0留给运行时去报错，常量折叠不应该提前把它变没
+		}
+		result.Div(left, right)
+	case MOD:
+		if right.Sign() == 0 {
+			return nil //模0同样留给运行时去报错
+		}
+		result.Mod(left, right)
+	case POWER:
+		if right.Sign() < 0 && left.Sign() == 0 {
+			return nil //0的负数次方是除0，留给运行时去报错
+		}
+		result.Pow(left, right)
+	default:
+		return nil
+	}
+
+	return numberLiteralExpr(result, s.Span())
+}
+
+//foldIdentities应用几条和具体字面量值无关的代数恒等式：x+0/0+x→x，
+//x*1/1*x→x，x*0/0*x→0(仅当x本身没有副作用时)，x-x→0，x/1→x
+func (s *AST_Arithmetic_Expression) foldIdentities() *AST_Arithmetic_Expression {
+	switch s.Op {
+	case ADD:
+		if isNumberLiteral(s.Right, 0) {
+			return s.Left
+		}
+		if isNumberLiteral(s.Left, 0) {
+			return s.Right
+		}
+	case SUB:
+		if isNumberLiteral(s.Right, 0) {
+			return s.Left
+		}
+		if sameOperand(s.Left, s.Right) {
+			return numberLiteralExpr(New_ASTNumber("0"), s.Span())
+		}
+	case MUL:
+		if isNumberLiteral(s.Right, 1) {
+			return s.Left
+		}
+		if isNumberLiteral(s.Left, 1) {
+			return s.Right
+		}
+		if isNumberLiteral(s.Right, 0) && !hasSideEffects(s.Left) {
+			return numberLiteralExpr(New_ASTNumber("0"), s.Span())
+		}
+		if isNumberLiteral(s.Left, 0) && !hasSideEffects(s.Right) {
+			return numberLiteralExpr(New_ASTNumber("0"), s.Span())
+		}
+	case DIV:
+		if isNumberLiteral(s.Right, 1) {
+			return s.Left
+		}
+	}
+	return nil
+}
+
+//Factor.Fold递归折叠括号里包着的子表达式；如果折叠完子表达式本身就退化成
+//一个字面量叶子，就把这层多余的括号也一起去掉，只保留字面量本身(位置信息
+//沿用这个factor自己的Span/Trivia，而不是内层字面量的，这样诊断信息仍然
+//指向源码里"("的位置)
+func (s *AST_Arithmetic_Expression_Factor) Fold() *AST_Arithmetic_Expression_Factor {
+	if s.Type != ARITHMETICEXPRESSION {
+		return s
+	}
+
+	folded := s.Value_Arithmetic_Expression.Fold()
+	if folded.Op != 0 {
+		s.Value_Arithmetic_Expression = folded
+		return s
+	}
+
+	span, trivia := s.Span, s.Trivia
+	*s = *folded.Factor
+	s.Span, s.Trivia = span, trivia
+	return s
+}
+
+//Fold折叠两个算术操作数，比较运算符本身不参与常量化简(a<b不会被提前算成
+//true/false，那属于Logical_Expression短路的范畴，不是这里要管的事)
+func (s *AST_Comparison_Expression) Fold() *AST_Comparison_Expression {
+	s.Left = s.Left.Fold()
+	if s.Type != 0 {
+		s.Right = s.Right.Fold()
+	}
+	return s
+}
+
+//literalBool判断这个节点自己的操作数(不含链条后续)是不是字面量true/false，
+//会把前面挂着的一元!一并算进去。只认裸的true/false字面量，带括号分组的
+//操作数暂时不展开判断
+func (s *AST_Logical_Expression) literalBool() (bool, bool) {
+	if s.IsGroup {
+		return false, false
+	}
+	if s.Value_Cmp.Type != 0 {
+		return false, false
+	}
+	arith := s.Value_Cmp.Left
+	if arith.Op != 0 || arith.Factor.Type != BOOL {
+		return false, false
+	}
+	value := arith.Factor.Value_Bool
+	if s.Not {
+		value = !value
+	}
+	return value, true
+}
+
+//Fold折叠&&/||链上的每个操作数，然后应用短路恒等式：true && e 折成e，
+//false || e 折成e
+func (s *AST_Logical_Expression) Fold() *AST_Logical_Expression {
+	if s.IsGroup {
+		s.Group = s.Group.Fold()
+	} else {
+		s.Value_Cmp = s.Value_Cmp.Fold()
+	}
+
+	if s.Type == 0 {
+		return s
+	}
+	s.Value_Exp = s.Value_Exp.Fold()
+
+	if value, ok := s.literalBool(); ok {
+		if (s.Type == LAND && value) || (s.Type == LOR && !value) {
+			return s.Value_Exp
+		}
+	}
+
+	return s
+}
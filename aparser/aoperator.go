@@ -0,0 +1,50 @@
+package aparser
+
+//operatorKey是OperatorRegistry查表用的三元组：运算符本身，加上左右操作数的类型
+type operatorKey struct {
+	Op  int
+	Lhs int
+	Rhs int
+}
+
+//OperatorBinding是命中operatorKey之后的结果：重载产出的类型，以及应该把
+//原本的算术节点改写成调用哪个函数
+type OperatorBinding struct {
+	ResultType int
+	CallTarget *AST_CallFuncStatement
+}
+
+//OperatorRegistry把(运算符, 左操作数类型, 右操作数类型)映射到一次函数调用。
+//CheckType撞上类型不一致时会先查一下这里有没有登记过对应的重载，而不是
+//直接当成类型冲突报错退出——这样STRING+NUMBER之类的组合就可以按需开出口子，
+//不用每加一种组合都去改CheckType本身
+type OperatorRegistry struct {
+	bindings map[operatorKey]OperatorBinding
+}
+
+//NewOperatorRegistry创建一个已经挂好内置重载的registry：STRING+STRING是
+//拼接，STRING*NUMBER(不论顺序)是重复
+func NewOperatorRegistry() *OperatorRegistry {
+	r := &OperatorRegistry{bindings: make(map[operatorKey]OperatorBinding)}
+	r.RegisterOperator(ADD, STRING, STRING, STRING, &AST_CallFuncStatement{Name: "string_concat"})
+	r.RegisterOperator(MUL, STRING, NUMBER, STRING, &AST_CallFuncStatement{Name: "string_repeat"})
+	r.RegisterOperator(MUL, NUMBER, STRING, STRING, &AST_CallFuncStatement{Name: "string_repeat"})
+	return r
+}
+
+//RegisterOperator登记一条重载绑定，重复登记同一个(op,lhs,rhs)会覆盖前一条
+func (r *OperatorRegistry) RegisterOperator(op, lhs, rhs, resultType int, fn *AST_CallFuncStatement) {
+	r.bindings[operatorKey{op, lhs, rhs}] = OperatorBinding{ResultType: resultType, CallTarget: fn}
+}
+
+//Lookup查找(op,lhs,rhs)对应的重载绑定，没有登记过或者registry本身是nil
+//(比如节点不是parseExpr造出来的)都返回nil
+func (r *OperatorRegistry) Lookup(op, lhs, rhs int) *OperatorBinding {
+	if r == nil {
+		return nil
+	}
+	if b, ok := r.bindings[operatorKey{op, lhs, rhs}]; ok {
+		return &b
+	}
+	return nil
+}
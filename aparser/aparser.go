@@ -5,7 +5,7 @@ import (
 	"container/list"
 	"fmt"
 	"io/ioutil"
-	"os"
+	"strings"
 )
 
 const (
@@ -25,6 +25,15 @@ const (
 	RAND = 14
 	STRING = 15
 	CHAR = 16
+	EQ = 17
+	NEQ = 18
+	LT = 19
+	LTE = 20
+	GT = 21
+	GTE = 22
+	LAND = 23
+	LOR = 24
+	LNOT = 25
 )
 
 type AParserError struct {
@@ -39,25 +48,70 @@ func (s *AParserError) Error() string {
 	return error_info
 }
 
+//Diagnose把一条AParserError渲染成"出错那一行源码 + 一个指向错误列的caret"的多行
+//格式，和HIL/Terraform的ast包展示多错误时的风格类似。source应该是产出这条错误
+//的那次ReadString/ReadBasicExpression调用所用的原始文本；source为空或者Line超出
+//范围时就退化成只打印单行的Error()。
+func (s *AParserError) Diagnose(source string) string {
+	line := sourceLine(source, s.Line)
+	if line == "" {
+		return s.Error()
+	}
+	column := s.Column - 1
+	if column < 0 {
+		column = 0
+	}
+	caret := strings.Repeat(" ", column) + "^"
+	return fmt.Sprintf("%s\n%s\n%s", s.Error(), line, caret)
+}
+
+//sourceLine取source的第lineNo行(从1开始计数)，行号越界时返回空字符串
+func sourceLine(source string, lineNo int) string {
+	lines := strings.Split(source, "\n")
+	if lineNo < 1 || lineNo > len(lines) {
+		return ""
+	}
+	return lines[lineNo-1]
+}
+
 type AParser struct {
-	AToken     *atoken.ATokenList
-	Error_List *list.List
-	File string
+	AToken      *atoken.ATokenList
+	Error_List  *list.List
+	Diagnostics DiagnosticSink
+	File        string
+	Trace       bool      //打开后每个产生式入口会打印一行跟踪日志，见aparser/trace
+	Program     *AST_Block //ReadString/ReadFile解析出的顶层语句块，供aprinter之类的下游工具使用
+	Operators   *OperatorRegistry //算术运算符的重载绑定表，见aoperator.go
+
+	hadError     bool
+	farthest     int
+	farthestToken *atoken.AToken
+	expectedSet  map[int]map[int]bool
 }
 
+//CheckUp把词法阶段(s.AToken.Error_list)积累下来的ALexError搬运到s.Diagnostics
+//里，不再中止进程——带有词法错误的源码仍然应该尽量被解析，这样使用者一次
+//就能看到词法+语法的全部问题，而不是在第一个词法错误上就把整个程序杀掉。
 func (s *AParser) CheckUp() {
-	if s.Error_List.Len() > 0 {
-		for i := s.Error_List.Front(); i != nil; i = i.Next() {
-			fmt.Println(i.Value.(*AParserError).Error())
-		}
-		os.Exit(1)
+	if s.AToken.Error_list == nil {
+		return
+	}
+	for i := s.AToken.Error_list.Front(); i != nil; i = i.Next() {
+		lexErr := i.Value.(*atoken.ALexError)
+		s.Diagnostics.Add(&Diagnostic{File: s.File, Line: lexErr.Line, Column: lexErr.Column, Production: lexErr.Message()})
 	}
 }
 
-func (s *AParser) ReadString(str string) {
+//ReadString解析一整段源码，返回解析过程中积累的全部诊断信息而不是在第一个
+//错误处杀掉进程，这样编辑器/linter之类的调用方可以拿到完整的错误列表。
+func (s *AParser) ReadString(str string) []*Diagnostic {
 	s.AToken.ReadString(str)
 	s.CheckUp()
-	s.MainStatement()
+	s.Program = s.MainStatement()
+	if final := s.FinalDiagnostic(); final != nil {
+		s.Diagnostics.Add(final)
+	}
+	return s.Diagnostics.All()
 }
 
 func (s *AParser) ReadBasicExpression(str string) *AST_Arithmetic_Expression{
@@ -66,26 +120,30 @@ func (s *AParser) ReadBasicExpression(str string) *AST_Arithmetic_Expression{
 	return s.Arithmetic_Expression()
 }
 
-func (s *AParser) ReadFile(path string) {
+func (s *AParser) ReadFile(path string) []*Diagnostic {
 	data, err := ioutil.ReadFile(path)
 	str_data := string(data)
 	if err != nil {
 		s.Error_List.PushBack(&AParserError{0, 0, path, "can't find this file!"})
-		return
+		return s.Diagnostics.All()
 	}
 
-	s.AToken.ReadString(str_data)
-	s.CheckUp()
+	return s.ReadString(str_data)
 }
 
 func (s *AParser) Init() *AParser {
 	s.AToken = atoken.New()
 	s.Error_List = list.New()
+	s.Operators = NewOperatorRegistry()
 	return s
 }
 
+//PushError记录一条语法错误。除了维持Error_List给旧的调用方使用，它现在还会
+//把同一条信息投进Diagnostics，这样ReadString才能把全部诊断一起交还给调用方。
 func (s *AParser) PushError(Line int,Column int,File string,error_info string){
+	s.hadError = true
 	s.Error_List.PushBack(&AParserError{Line, Column, File, error_info})
+	s.Diagnostics.Add(&Diagnostic{File: File, Line: Line, Column: Column, Production: error_info})
 	fmt.Println(s.Error_List.Front().Value.(*AParserError).Error())
 }
 
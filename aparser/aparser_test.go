@@ -3,7 +3,8 @@ package aparser
 import (
 	"fmt"
 	//"fmt"
-//	"math/big"
+	"math/big"
+	"strings"
 	"testing"
 )
 
@@ -28,7 +29,7 @@ func Test_BigNumber(t *testing.T){
 
 func Test_ReadString(t *testing.T) {
 	parser := New()
-	parser.ReadString(`
+	diagnostics := parser.ReadString(`
 	var fuck = 10
 
 	if ((30+4>4+4+5&&fuck>3)&&(30>2)){
@@ -69,6 +70,188 @@ func Test_ReadString(t *testing.T) {
 
 	`)
 
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics for valid source, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+}
+
+func Test_ReadString_RecoversFromError(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	var fuck = 10
+	var 123 = 20
+	var ok = 30
+	`)
+
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected at least one diagnostic for malformed source, got none")
+	}
+}
+
+func Test_DeclModifiers(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	extern func fucker(var wokao -> int) -> int{
+		return 1
+	}
+
+	static ghost var fuck = 10
+	`)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics for valid modifiers, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+
+	stmt := parser.Program.Statements[0]
+	if !stmt.Func.Modifiers.IsExtern {
+		t.Fatalf("expected fucker to be parsed as extern")
+	}
+
+	stmt = parser.Program.Statements[1]
+	if !stmt.Var.Modifiers.IsStatic || !stmt.Var.Modifiers.IsGhost {
+		t.Fatalf("expected fuck to be parsed as static ghost")
+	}
+}
+
+func Test_ReadString_SurfacesLexErrorsAsDiagnosticsInsteadOfExiting(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`var fuck = "bad \q escape"`) // used to os.Exit(1) the test binary
+
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected the bad escape to surface as a diagnostic, got none")
+	}
+	found := false
+	for _, d := range diagnostics {
+		if strings.Contains(d.Error(), `unknown escape sequence`) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a diagnostic mentioning the unknown escape sequence, got %+v", diagnostics)
+	}
+}
+
+func Test_DeclModifiers_StaticLocalVarIsRejected(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	func fucker() -> int{
+		static var fuck = 10
+		return fuck
+	}
+	`)
+
+	if len(diagnostics) == 0 {
+		t.Fatalf("expected a diagnostic for 'static' on a local var, got none")
+	}
+}
+
+func Test_StringLiteral_Interpolation(t *testing.T) {
+	parser := New()
+	ast_exp := parser.ReadBasicExpression(`"hello $name and ${1+2}!"`)
+	factor := ast_exp.Factor
+	if factor.Type != STRING {
+		t.Fatalf("expected a STRING factor, got %d", factor.Type)
+	}
+
+	parts := factor.Value_String.Parts
+	if len(parts) != 5 {
+		t.Fatalf("expected 5 string parts, got %d: %+v", len(parts), parts)
+	}
+	if parts[0].Kind != StringPartRaw || parts[0].Raw != "hello " {
+		t.Fatalf("expected leading raw text, got %+v", parts[0])
+	}
+	if parts[1].Kind != StringPartVarRef || parts[1].VarRef != "name" {
+		t.Fatalf("expected a $name var ref, got %+v", parts[1])
+	}
+	if parts[2].Kind != StringPartRaw || parts[2].Raw != " and " {
+		t.Fatalf("expected a raw separator, got %+v", parts[2])
+	}
+	if parts[3].Kind != StringPartExpr || parts[3].Expr == nil {
+		t.Fatalf("expected a ${expr} part, got %+v", parts[3])
+	}
+	if parts[4].Kind != StringPartRaw || parts[4].Raw != "!" {
+		t.Fatalf("expected trailing raw text, got %+v", parts[4])
+	}
+}
+
+func Test_Heredoc(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString("var doc = <<<EOT\nline one\nline two\nEOT\n")
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+
+	factor := parser.Program.Statements[0].Var.Assign.Values[0].Factor
+	if factor.Type != STRING {
+		t.Fatalf("expected a STRING factor, got %d", factor.Type)
+	}
+	if len(factor.Value_String.Parts) != 1 || factor.Value_String.Parts[0].Raw != "line one\nline two" {
+		t.Fatalf("unexpected heredoc body: %+v", factor.Value_String.Parts)
+	}
+}
+
+func Test_Heredoc_Empty(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString("var doc = <<<EOT\nEOT\n")
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+
+	factor := parser.Program.Statements[0].Var.Assign.Values[0].Factor
+	if len(factor.Value_String.Parts) != 0 {
+		t.Fatalf("expected an empty heredoc to have no parts, got %+v", factor.Value_String.Parts)
+	}
+}
+
+func Test_Keywords_NoPrefixCollisions(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	for (var i = 0;i<10;i = i + 1){
+		if (i>5){
+			continue
+		}elseif(i<1){
+			break
+		}
+	}
+
+	static ghost extern var fuck = 10
+	`)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+}
+
+func Test_Keywords_UnrecognizedIdentifierIsWord(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	var elsewhere = 10
+	var newspaper = 20
+	`)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+}
+
+func Test_Comments_AreDiscardedByDefault(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	// a leading comment
+	var fuck = 10 // trailing comment
+	/* a
+	   block comment */
+	var ok = 20
+	`)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+	if len(parser.Program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(parser.Program.Statements))
+	}
 }
 
 func Test_ReadExpression(t * testing.T){
@@ -76,4 +259,423 @@ func Test_ReadExpression(t * testing.T){
 	ast_basic_exp := parser.ReadBasicExpression(`1*2+true+4+(5+false)`)
 	//fmt.Println(ast_basic_exp.Type)
 	ast_basic_exp.CheckType()
-}
\ No newline at end of file
+}
+
+func Test_CheckType_AccumulatesTypeErrorsInsteadOfExiting(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`(1+true)+(2+"s")`)
+
+	_, errs := exp.CheckType()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 accumulated type errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func Test_CheckType_NoClashReturnsNoErrors(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`1+2*3`)
+
+	Type, errs := exp.CheckType()
+	if len(errs) != 0 {
+		t.Fatalf("expected no type errors, got %v", errs)
+	}
+	if Type != NUMBER {
+		t.Fatalf("expected NUMBER, got %d", Type)
+	}
+}
+
+func Test_CheckType_RegisteredOverloadRewritesIntoACall(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`"a"+"b"`)
+
+	Type, errs := exp.CheckType()
+	if len(errs) != 0 {
+		t.Fatalf("expected the STRING+STRING overload to avoid a clash error, got %v", errs)
+	}
+	if Type != STRING {
+		t.Fatalf("expected STRING, got %d", Type)
+	}
+	if exp.Call == nil || exp.Call.Name != "string_concat" {
+		t.Fatalf("expected the node to be rewritten into a string_concat call, got %+v", exp.Call)
+	}
+}
+
+func Test_CheckType_UnregisteredClashStillErrors(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`1+true`)
+
+	_, errs := exp.CheckType()
+	if len(errs) != 1 {
+		t.Fatalf("expected NUMBER+BOOL to still be an unresolved clash, got %d errors", len(errs))
+	}
+	if exp.Call != nil {
+		t.Fatalf("expected no overload to be applied, got %+v", exp.Call)
+	}
+}
+
+func Test_CheckType_OverloadRewriteCarriesItsOwnOperands(t *testing.T) {
+	parser := New()
+	exp1 := parser.ReadBasicExpression(`"a"+"b"`)
+	exp2 := parser.ReadBasicExpression(`"c"+"d"`)
+
+	exp1.CheckType()
+	exp2.CheckType()
+
+	if len(exp1.Call.Args) != 2 || exp1.Call.Args[0] != exp1.Left || exp1.Call.Args[1] != exp1.Right {
+		t.Fatalf("expected exp1.Call.Args to be exp1's own operands, got %+v", exp1.Call.Args)
+	}
+	if exp1.Call == exp2.Call {
+		t.Fatalf("expected each node to get its own *AST_CallFuncStatement, got the same pointer")
+	}
+}
+
+func Test_OperatorRegistry_CustomBindingIsConsulted(t *testing.T) {
+	parser := New()
+	parser.Operators.RegisterOperator(ADD, BOOL, BOOL, BOOL, &AST_CallFuncStatement{Name: "bool_or"})
+	exp := parser.ReadBasicExpression(`true+false`)
+
+	Type, errs := exp.CheckType()
+	if len(errs) != 0 {
+		t.Fatalf("expected the custom BOOL+BOOL overload to apply, got %v", errs)
+	}
+	if Type != BOOL {
+		t.Fatalf("expected BOOL, got %d", Type)
+	}
+	if exp.Call == nil || exp.Call.Name != "bool_or" {
+		t.Fatalf("expected the node to call bool_or, got %+v", exp.Call)
+	}
+}
+
+func Test_Fold_ConstantArithmeticEvaluatesEagerly(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`1+2*3`)
+
+	folded := exp.Fold()
+	if folded.Op != 0 {
+		t.Fatalf("expected the whole literal subtree to collapse to a leaf, got Op %d", folded.Op)
+	}
+	if folded.Factor.Value_Number.RatString() != "7" {
+		t.Fatalf("expected 7, got %s", folded.Factor.Value_Number.RatString())
+	}
+}
+
+func Test_Fold_AdditiveIdentityDropsTheZero(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`x+0`)
+
+	folded := exp.Fold()
+	if folded.Op != 0 || folded.Factor.Type != VAR || folded.Factor.Value_VarWord != "x" {
+		t.Fatalf("expected 'x+0' to fold down to the bare variable x, got %+v", folded)
+	}
+}
+
+func Test_Fold_MultiplyByZeroBecomesZero(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`x*0`)
+
+	folded := exp.Fold()
+	if folded.Op != 0 || folded.Factor.Type != NUMBER || folded.Factor.Value_Number.RatString() != "0" {
+		t.Fatalf("expected 'x*0' to fold down to the literal 0, got %+v", folded)
+	}
+}
+
+func Test_Fold_MultiplyByZeroKeepsACallWithSideEffects(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`foo(1,2)*0`)
+
+	folded := exp.Fold()
+	if folded.Op != MUL || folded.Left.Factor.Type != CALLFUNC {
+		t.Fatalf("expected 'foo(1,2)*0' to keep the call to foo, got %+v", folded)
+	}
+}
+
+func Test_Fold_SubtractingSameVariableBecomesZero(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`x-x`)
+
+	folded := exp.Fold()
+	if folded.Op != 0 || folded.Factor.Type != NUMBER || folded.Factor.Value_Number.RatString() != "0" {
+		t.Fatalf("expected 'x-x' to fold down to the literal 0, got %+v", folded)
+	}
+}
+
+func Test_Fold_RedundantParensCollapseToTheLiteral(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`(1+2)`)
+
+	folded := exp.Fold()
+	if folded.Op != 0 || folded.Factor.Type != NUMBER || folded.Factor.Value_Number.RatString() != "3" {
+		t.Fatalf("expected '(1+2)' to fold down to the literal 3, got %+v", folded)
+	}
+}
+
+func Test_Fold_LogicalShortCircuitsTrueAnd(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("true && 1 < 2\n")
+	logical := parser.Logical_Expression()
+
+	folded := logical.Fold()
+	if folded.IsGroup || folded.Value_Cmp.Type != LT {
+		t.Fatalf("expected 'true && 1<2' to short-circuit down to the '1<2' comparison, got %+v", folded)
+	}
+}
+
+func Test_Span_CoversTheWholeExpression(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`1+22`)
+
+	span := exp.Span()
+	if span.StartCol != 1 {
+		t.Fatalf("expected the span to start at column 1, got %d", span.StartCol)
+	}
+	if span.EndCol != 5 {
+		t.Fatalf("expected the span to end right after '22', got %d", span.EndCol)
+	}
+}
+
+func Test_Arithmetic_Expression_PowerBindsTighterThanMul(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`2*3^4`)
+
+	if exp.Op != MUL {
+		t.Fatalf("expected the top node to be the '*', got Op %d", exp.Op)
+	}
+	if exp.Right.Op != POWER {
+		t.Fatalf("expected '3^4' to bind together under '^', got Op %d", exp.Right.Op)
+	}
+}
+
+func Test_Arithmetic_Expression_SubIsLeftAssociative(t *testing.T) {
+	parser := New()
+	exp := parser.ReadBasicExpression(`10-2-3`)
+
+	if exp.Op != SUB {
+		t.Fatalf("expected the top node to be the last '-', got Op %d", exp.Op)
+	}
+	if exp.Left.Op != SUB {
+		t.Fatalf("expected '10-2' to group on the left, got Op %d", exp.Left.Op)
+	}
+	if exp.Right.Op != 0 {
+		t.Fatalf("expected the right operand to be the leaf '3', got Op %d", exp.Right.Op)
+	}
+}
+
+func Test_AParserError_Diagnose_PointsACaretAtTheColumn(t *testing.T) {
+	err := &AParserError{Line: 1, Column: 3, File: "test", error_info: "boom"}
+
+	out := err.Diagnose("1+true")
+	if !strings.Contains(out, "1+true") {
+		t.Fatalf("expected the offending source line to be echoed back, got %q", out)
+	}
+	if !strings.Contains(out, "  ^") {
+		t.Fatalf("expected a caret under column 3, got %q", out)
+	}
+}
+
+func Test_Comparison_Expression_ReturnsBool(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("1 < 2\n")
+
+	cmp := parser.Comparison_Expression()
+	Type, errs := cmp.CheckType()
+	if len(errs) != 0 {
+		t.Fatalf("expected no type errors, got %v", errs)
+	}
+	if Type != BOOL {
+		t.Fatalf("expected BOOL, got %d", Type)
+	}
+	if cmp.Type != LT {
+		t.Fatalf("expected LT, got %d", cmp.Type)
+	}
+}
+
+func Test_Logical_Expression_ChainsAndOr(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("1 < 2 && true || 2 >= 3\n")
+
+	logical := parser.Logical_Expression()
+	if logical.Type != LAND {
+		t.Fatalf("expected the first link to combine with &&, got %d", logical.Type)
+	}
+	if logical.Value_Exp == nil || logical.Value_Exp.Type != LOR {
+		t.Fatalf("expected the second link to combine with ||, got %+v", logical.Value_Exp)
+	}
+	Type, errs := logical.CheckType()
+	if len(errs) != 0 {
+		t.Fatalf("expected no type errors, got %v", errs)
+	}
+	if Type != BOOL {
+		t.Fatalf("expected BOOL, got %d", Type)
+	}
+}
+
+func Test_Logical_Expression_NotAndGroup(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("!(1 == 1)\n")
+
+	logical := parser.Logical_Expression()
+	if !logical.Not {
+		t.Fatalf("expected the leading ! to be recorded")
+	}
+	if !logical.IsGroup || logical.Group == nil {
+		t.Fatalf("expected the parenthesized part to parse as a nested group, got %+v", logical)
+	}
+	if Type, errs := logical.CheckType(); Type != BOOL || len(errs) != 0 {
+		t.Fatalf("expected BOOL with no errors, got %d, %v", Type, errs)
+	}
+}
+
+func Test_Logical_Expression_MixingNonBoolIsAnError(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("1 && true\n")
+
+	logical := parser.Logical_Expression()
+	_, errs := logical.CheckType()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 type error for mixing a number into &&, got %d: %v", len(errs), errs)
+	}
+}
+
+func Test_Conditional_Expression_BranchTypesMustUnify(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("1 < 2 ? 3 : true\n")
+
+	cond := parser.Conditional_Expression()
+	_, errs := cond.CheckType()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 type error for mismatched branches, got %d: %v", len(errs), errs)
+	}
+}
+
+func Test_Conditional_Expression_MatchingBranchesCheckClean(t *testing.T) {
+	parser := New()
+	parser.AToken.ReadString("1 < 2 ? 3 : 4\n")
+
+	cond := parser.Conditional_Expression()
+	Type, errs := cond.CheckType()
+	if len(errs) != 0 {
+		t.Fatalf("expected no type errors, got %v", errs)
+	}
+	if Type != NUMBER {
+		t.Fatalf("expected NUMBER, got %d", Type)
+	}
+}
+
+func Test_IfStatement_CondIsASTified(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	if (1 < 2 && true) {
+		var x = 1
+	}
+	`)
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+
+	ifStmt := parser.Program.Statements[0].If
+	if ifStmt.Cond == nil {
+		t.Fatalf("expected the if condition to be ASTified")
+	}
+	if Type, errs := ifStmt.Cond.CheckType(); Type != BOOL || len(errs) != 0 {
+		t.Fatalf("expected BOOL with no errors, got %d, %v", Type, errs)
+	}
+}
+
+func Test_ForStatement_CondIsASTified(t *testing.T) {
+	parser := New()
+	diagnostics := parser.ReadString(`
+	for (var i = 0; i < 10; i = i + 1) {
+		var w = i
+	}
+	`)
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+
+	forStmt := parser.Program.Statements[0].For
+	if forStmt.Cond == nil {
+		t.Fatalf("expected the for condition to be ASTified")
+	}
+}
+func Test_ASTNumber_ModOnFractions(t *testing.T) {
+	a := New_ASTNumber("7/2")  // 3.5
+	b := New_ASTNumber("3/2")  // 1.5
+
+	result := new(AST_Number)
+	result.Rat = new(big.Rat)
+	result.Mod(a, b)
+
+	if result.RatString() != "1/2" {
+		t.Fatalf("expected 7/2 mod 3/2 to be 1/2, got %s", result.RatString())
+	}
+}
+
+func Test_ASTNumber_ModOnIntegers(t *testing.T) {
+	a := New_ASTNumber("17")
+	b := New_ASTNumber("5")
+
+	result := new(AST_Number)
+	result.Rat = new(big.Rat)
+	result.Mod(a, b)
+
+	if result.RatString() != "2" {
+		t.Fatalf("expected 17 mod 5 to be 2, got %s", result.RatString())
+	}
+}
+
+func Test_ASTNumber_PowIntegerExponentIsExact(t *testing.T) {
+	base := New_ASTNumber("2/3")
+	exp := New_ASTNumber("3")
+
+	result := new(AST_Number)
+	result.Rat = new(big.Rat)
+	result.Pow(base, exp)
+
+	if result.RatString() != "8/27" {
+		t.Fatalf("expected (2/3)^3 to be exactly 8/27, got %s", result.RatString())
+	}
+}
+
+func Test_ASTNumber_PowNegativeIntegerExponentInverts(t *testing.T) {
+	base := New_ASTNumber("2")
+	exp := New_ASTNumber("-3")
+
+	result := new(AST_Number)
+	result.Rat = new(big.Rat)
+	result.Pow(base, exp)
+
+	if result.RatString() != "1/8" {
+		t.Fatalf("expected 2^-3 to be 1/8, got %s", result.RatString())
+	}
+}
+
+func Test_ASTNumber_PowFractionalExponentApproximates(t *testing.T) {
+	base := New_ASTNumber("4")
+	exp := New_ASTNumber("1/2")
+
+	result := new(AST_Number)
+	result.Rat = new(big.Rat)
+	result.Pow(base, exp)
+
+	got, _ := result.Float64()
+	if got < 1.999 || got > 2.001 {
+		t.Fatalf("expected 4^0.5 to approximate 2, got %v", got)
+	}
+}
+
+func Test_ASTNumber_IntegerFastPathStaysExact(t *testing.T) {
+	a := New_ASTNumber("10")
+	b := New_ASTNumber("4")
+
+	result := new(AST_Number)
+	result.Rat = new(big.Rat)
+	result.Add(a, b)
+	if result.RatString() != "14" {
+		t.Fatalf("expected 10+4 to be 14, got %s", result.RatString())
+	}
+
+	result.Mul(a, b)
+	if result.RatString() != "40" {
+		t.Fatalf("expected 10*4 to be 40, got %s", result.RatString())
+	}
+}
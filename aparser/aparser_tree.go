@@ -1,21 +1,23 @@
 package aparser
 
 import (
-	"fmt"
-	"os"
-	//"fmt"
 	"atoken"
+	"aparser/trace"
 )
 
 //主语句BNF
-func (s *AParser) MainStatement() {
-	fmt.Println("MainStatement")
-	s.Statement()
-	s.TMP_MainStatement()
+func (s *AParser) MainStatement() *AST_Block {
+	trace.Println(s.Trace, "MainStatement")
+	result := new(AST_Block)
+	if stmt, _ := s.Statement(); stmt != nil {
+		result.Statements = append(result.Statements, stmt)
+	}
+	s.TMP_MainStatement(result)
+	return result
 }
 
-func (s *AParser) TMP_MainStatement() {
-	fmt.Println("TMP_MainStatement")
+func (s *AParser) TMP_MainStatement(result *AST_Block) {
+	trace.Println(s.Trace, "TMP_MainStatement")
 	if s.AToken.IsEnd() {
 		return
 	}
@@ -24,10 +26,13 @@ func (s *AParser) TMP_MainStatement() {
 		return
 	}
 
-	fmt.Println("MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Statement")
+	trace.Println(s.Trace, "MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Statement")
 	if token.Type == atoken.EOF {
-		if s.Statement() {
-			s.TMP_MainStatement()
+		if stmt, ok := s.Statement(); ok {
+			if stmt != nil {
+				result.Statements = append(result.Statements, stmt)
+			}
+			s.TMP_MainStatement(result)
 		} else {
 
 			return
@@ -38,23 +43,76 @@ func (s *AParser) TMP_MainStatement() {
 	}
 }
 
-func (s *AParser) Statement() bool {
-	fmt.Println("Statement")
+//parseDeclModifiers在func/var前面消费static/extern/ghost修饰符。isLocal标记
+//调用方是不是块内的局部声明——static修饰局部var没有意义，这里报错但继续解析。
+func (s *AParser) parseDeclModifiers(isLocal bool) DeclModifiers {
+	trace.Println(s.Trace, "parseDeclModifiers")
+	var mods DeclModifiers
+	for {
+		token := s.AToken.GetToken()
+		switch token.Type {
+		case atoken.STATIC:
+			if mods.IsStatic {
+				s.PushError(token.Line, token.Column, s.File, "duplicate modifier 'static' ->parseDeclModifiers")
+				continue
+			}
+			if isLocal {
+				s.PushError(token.Line, token.Column, s.File, "modifier 'static' is not applicable to a local var ->parseDeclModifiers")
+			}
+			mods.IsStatic = true
+			mods.StaticToken = token
+
+		case atoken.EXTERN:
+			if mods.IsExtern {
+				s.PushError(token.Line, token.Column, s.File, "duplicate modifier 'extern' ->parseDeclModifiers")
+				continue
+			}
+			mods.IsExtern = true
+			mods.ExternToken = token
+
+		case atoken.GHOST:
+			if mods.IsGhost {
+				s.PushError(token.Line, token.Column, s.File, "duplicate modifier 'ghost' ->parseDeclModifiers")
+				continue
+			}
+			mods.IsGhost = true
+			mods.GhostToken = token
+
+		default:
+			s.AToken.BackToken()
+			return mods
+		}
+	}
+}
+
+func (s *AParser) Statement() (*AST_Statement, bool) {
+	trace.Println(s.Trace, "Statement")
 	token := s.AToken.GetToken()
-	fmt.Println("MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Statement")
+	trace.Println(s.Trace, "MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Statement")
 	s.AToken.BackToken()
 	switch token.Type {
+	case atoken.STATIC, atoken.EXTERN, atoken.GHOST:
+		mods := s.parseDeclModifiers(false)
+		next := s.AToken.GetToken()
+		s.AToken.BackToken()
+		if next.Type == atoken.FUNC {
+			return &AST_Statement{Kind: STMT_FUNC, Func: s.FuncStatement(mods)}, true
+		}
+		if next.Type == atoken.VAR {
+			return &AST_Statement{Kind: STMT_VAR, Var: s.VarStatement(mods)}, true
+		}
+		s.PushError(next.Line, next.Column, s.File, "unexpected "+next.Value+" expecting 'func' || 'var' after declaration modifiers ->Statement")
+		s.Synchronize()
+		return nil, false
+
 	case atoken.FUNC:
-		s.FuncStatement()
-		break
+		return &AST_Statement{Kind: STMT_FUNC, Func: s.FuncStatement(DeclModifiers{})}, true
 
 	case atoken.VAR:
-		s.VarStatement()
-		break
+		return &AST_Statement{Kind: STMT_VAR, Var: s.VarStatement(DeclModifiers{})}, true
 
 	case atoken.LP:
-		s.BlockStatement()
-		break
+		return &AST_Statement{Kind: STMT_BLOCK, Block: s.BlockStatement()}, true
 
 	case atoken.WORD:
 		s.AToken.GetToken()
@@ -62,73 +120,58 @@ func (s *AParser) Statement() bool {
 		if s_token.Type == atoken.LP { //<CallFuncStatement>
 			s.AToken.BackToken()
 			s.AToken.BackToken()
-			s.CallFuncStatement()
+			return &AST_Statement{Kind: STMT_CALL, Call: s.CallFuncStatement()}, true
 		} else if s_token.Type == atoken.ASSIGMENT || s_token.Type == atoken.COMMA {
 			s.AToken.BackToken()
 			s.AToken.BackToken()
-			s.AssigmentStatement()
+			return &AST_Statement{Kind: STMT_ASSIGN, Assign: s.AssigmentStatement()}, true
 		}
-		break
+		return nil, true
 
 	case atoken.EOF:
-
-		break
+		return nil, true
 
 	case atoken.FOR:
-		s.ForStatement()
-		break
+		return &AST_Statement{Kind: STMT_FOR, For: s.ForStatement()}, true
 
 	case atoken.IF:
-		s.IFStatement()
-		break
+		return &AST_Statement{Kind: STMT_IF, If: s.IFStatement()}, true
 
 	default:
 		s.PushError(token.Line, token.Column, s.File, "unexpected "+token.Value+" expecting 'func' || '=' || 'var' || '( ' || 'WORD' || '\r\n' || '\\n' || 'for' || 'break' || 'continue' ->Statement")
-		os.Exit(1)
-		return false
+		s.Synchronize()
+		return nil, false
 		//error
-		//fmt.Println("error:unexpected")
-		break
+		//trace.Println(s.Trace, "error:unexpected")
 	}
-	return true
 }
 
 //函数声明语句 func(xxx,xxx,xxx)
 
-func (s *AParser) FuncStatement() {
-	fmt.Println("FuncStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.FUNC {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.WORD {
-			if token_3 := s.AToken.GetToken(); token_3.Type == atoken.LP {
-				s.FuncStatement_Define()
-				if token_4 := s.AToken.GetToken(); token_4.Type == atoken.RP {
-					if token_5 := s.AToken.GetToken(); token_5.Type == atoken.CASTING {
-						s.FuncReturnType()
-					} else {
-						s.PushError(token_4.Line, token_4.Column, s.File, "unexpected "+token_4.Value+" expecting '->(CASTRING)' ->FuncStatement")
-						os.Exit(1)
-					}
-					s.BlockStatement()
-				} else {
-					//error
-					s.PushError(token_4.Line, token_4.Column, s.File, "unexpected "+token_4.Value+" expecting ')' ->FuncStatement")
-					os.Exit(1)
-				}
-			} else {
-				//error
-				s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting '(' ->FuncStatement")
-				os.Exit(1)
-			}
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting 'WORD' ->FuncStatement")
-			os.Exit(1)
-		}
-	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'func' ->FUncStatement")
-		os.Exit(1)
+func (s *AParser) FuncStatement(mods DeclModifiers) *AST_FuncStatement {
+	trace.Println(s.Trace, "FuncStatement")
+	result := new(AST_FuncStatement)
+	result.Modifiers = mods
+	if _, ok := s.expect(atoken.FUNC, "FuncStatement"); !ok {
+		return result
+	}
+	name_token, ok := s.expect(atoken.WORD, "FuncStatement")
+	if !ok {
+		return result
+	}
+	result.Name = name_token.Value
+	if _, ok := s.expect(atoken.LP, "FuncStatement"); !ok {
+		return result
+	}
+	s.FuncStatement_Define()
+	if _, ok := s.expect(atoken.RP, "FuncStatement"); !ok {
+		return result
 	}
+	if _, ok := s.expect(atoken.CASTING, "FuncStatement"); ok {
+		s.FuncReturnType()
+	}
+	result.Body = s.BlockStatement()
+	return result
 }
 
 func (s *AParser) FuncReturnType() {
@@ -144,24 +187,18 @@ func (s *AParser) FuncReturnType() {
 }
 
 func (s *AParser) FuncReturnType_Factor() {
-	token := s.AToken.GetToken()
-	if token.Type == atoken.WORD {
-
-	} else {
-		s.PushError(token.Line, token.Column, s.File, "unexpected "+token.Value+" expecting 'Type' ->FuncReturnType_Factor")
-		os.Exit(1)
-	}
+	s.expect(atoken.WORD, "FuncReturnType_Factor")
 }
 
 //函数参数声明 xxx,xxx,xxx
 func (s *AParser) FuncStatement_Define() {
-	fmt.Println("FuncStatement_Define")
+	trace.Println(s.Trace, "FuncStatement_Define")
 	s.FuncStatement_Define_Factor()
 	s.TMP_FuncStatement_Define()
 }
 
 func (s *AParser) TMP_FuncStatement_Define() {
-	fmt.Println("TMP_FuncStatement_Define")
+	trace.Println(s.Trace, "TMP_FuncStatement_Define")
 	if s.AToken.GetToken().Type == atoken.COMMA {
 		s.FuncStatement_Define_Factor()
 		s.TMP_FuncStatement_Define()
@@ -171,100 +208,73 @@ func (s *AParser) TMP_FuncStatement_Define() {
 }
 
 func (s *AParser) BreakStatement() {
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.BREAK {
-
-	} else {
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'break' ->BreakStatement")
-		os.Exit(1)
-	}
+	s.expect(atoken.BREAK, "BreakStatement")
 }
 
 func (s *AParser) ContinueStatement() {
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.CONTINUE {
-
-	} else {
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'continue' ->ContinueStatement")
-		os.Exit(1)
-	}
+	s.expect(atoken.CONTINUE, "ContinueStatement")
 }
 
 func (s *AParser) FuncStatement_Define_Factor() {
-	fmt.Println("FuncStatement_Define_Factor")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.VAR {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.WORD {
-			if token_3 := s.AToken.GetToken(); token_3.Type == atoken.CASTING {
-				if token_4 := s.AToken.GetToken(); token_4.Type == atoken.WORD {
-
-				} else {
-					//error
-					s.PushError(token_4.Line, token_4.Column, s.File, "unexpected "+token_4.Value+" expecting 'WORD' ->FuncStatement_Define_Factor")
-					os.Exit(1)
-				}
-			} else {
-				//error
-				s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting 'CASTING' ->FuncStatement_Define_Factor")
-				os.Exit(1)
-			}
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting 'WORD' ->FuncStatement_Define_Factor")
-			os.Exit(1)
-		}
-	} else {
-		//error
-		//		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'Var' ->FuncStatement_Define_Factor")
-		//		os.Exit(1)
+	trace.Println(s.Trace, "FuncStatement_Define_Factor")
+	if s.AToken.GetToken().Type != atoken.VAR {
+		//允许参数列表为空
 		s.AToken.BackToken()
+		return
 	}
+	if _, ok := s.expect(atoken.WORD, "FuncStatement_Define_Factor"); !ok {
+		return
+	}
+	if _, ok := s.expect(atoken.CASTING, "FuncStatement_Define_Factor"); !ok {
+		return
+	}
+	s.expect(atoken.WORD, "FuncStatement_Define_Factor")
 }
 
 //函数返回语句
-func (s *AParser) FuncStatement_Return() {
+func (s *AParser) FuncStatement_Return() *AST_ReturnStatement {
+	result := new(AST_ReturnStatement)
 	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.RETURN {
 		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.EOF {
-			return
+			return result
 		} else {
 			s.AToken.BackToken()
 		}
-		s.Arithmetic_Expression()
+		result.Values = append(result.Values, s.Arithmetic_Expression())
 		for {
 			if token_3 := s.AToken.GetToken(); token_3.Type != atoken.COMMA {
 				s.AToken.BackToken()
 				break
 			}
 
-			s.Arithmetic_Expression()
+			result.Values = append(result.Values, s.Arithmetic_Expression())
 		}
 	}
+	return result
 }
 
 //赋值语句
-func (s *AParser) AssigmentStatement() {
-	fmt.Println("AssigmentStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.WORD {
-		s.TMP_AssigmentStatement()
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.ASSIGMENT {
-			s.MoreArithmetic_Expression()
-		} else {
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting '=' ->AssigmentStatement")
-			os.Exit(1)
-		}
-	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'WORD' ->AssigmentStatement")
-		os.Exit(1)
+func (s *AParser) AssigmentStatement() *AST_AssigmentStatement {
+	trace.Println(s.Trace, "AssigmentStatement")
+	result := new(AST_AssigmentStatement)
+	name_token, ok := s.expect(atoken.WORD, "AssigmentStatement")
+	if !ok {
+		return result
 	}
+	result.Names = append(result.Names, name_token.Value)
+	s.TMP_AssigmentStatement(result)
+	if _, ok := s.expect(atoken.ASSIGMENT, "AssigmentStatement"); ok {
+		result.Values = s.MoreArithmetic_Expression()
+	}
+	return result
 }
 
-func (s *AParser) TMP_AssigmentStatement() {
-	fmt.Println("TMP_AssigmentStatement")
+func (s *AParser) TMP_AssigmentStatement(result *AST_AssigmentStatement) {
+	trace.Println(s.Trace, "TMP_AssigmentStatement")
 	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.COMMA {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.WORD {
-			s.TMP_AssigmentStatement()
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting 'WORD' ->TMP_AssigmentStatement")
-			os.Exit(1)
+		if name_token, ok := s.expect(atoken.WORD, "TMP_AssigmentStatement"); ok {
+			result.Names = append(result.Names, name_token.Value)
+			s.TMP_AssigmentStatement(result)
 		}
 	} else {
 		s.AToken.BackToken()
@@ -272,66 +282,55 @@ func (s *AParser) TMP_AssigmentStatement() {
 }
 
 //多重表达式语句 xxx,xxx,xxx,xxx
-func (s *AParser) MoreArithmetic_Expression() {
-	fmt.Println("MoreArithmetic_Expression")
-	s.Arithmetic_Expression()
-	s.TMP_MoreArithmetic_Expression()
+func (s *AParser) MoreArithmetic_Expression() []*AST_Arithmetic_Expression {
+	trace.Println(s.Trace, "MoreArithmetic_Expression")
+	result := []*AST_Arithmetic_Expression{s.Arithmetic_Expression()}
+	return s.TMP_MoreArithmetic_Expression(result)
 }
 
-func (s *AParser) TMP_MoreArithmetic_Expression() {
-	fmt.Println("TMP_MoreArithmetic_Expression")
+func (s *AParser) TMP_MoreArithmetic_Expression(result []*AST_Arithmetic_Expression) []*AST_Arithmetic_Expression {
+	trace.Println(s.Trace, "TMP_MoreArithmetic_Expression")
 	if s.AToken.GetToken().Type == atoken.COMMA {
-		s.Arithmetic_Expression()
+		result = append(result, s.Arithmetic_Expression())
 	} else {
 		s.AToken.BackToken()
 	}
+	return result
 }
 
 //判断语句
-func (s *AParser) IFStatement() {
-	fmt.Println("IFStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.IF {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.LP {
-			s.Boolean_Expression()
-			if token_3 := s.AToken.GetToken(); token_3.Type == atoken.RP {
-				s.BlockStatement()
-				s.IFStatement_ELSE()
-			} else {
-				//error
-				s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting ')' ->IFStatement")
-				os.Exit(1)
-			}
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting '(' ->IFStatement")
-			os.Exit(1)
-		}
-	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'if' ->IFStatement")
-		os.Exit(1)
+func (s *AParser) IFStatement() *AST_IfStatement {
+	trace.Println(s.Trace, "IFStatement")
+	result := new(AST_IfStatement)
+	if _, ok := s.expect(atoken.IF, "IFStatement"); !ok {
+		return result
 	}
+	if _, ok := s.expect(atoken.LP, "IFStatement"); !ok {
+		return result
+	}
+	result.Cond = s.Logical_Expression()
+	if _, ok := s.expect(atoken.RP, "IFStatement"); !ok {
+		return result
+	}
+	result.Then = s.BlockStatement()
+	s.IFStatement_ELSE(result)
+	return result
 }
 
-func (s *AParser) IFStatement_ELSE() {
-	fmt.Println("IFStatement_ELSE")
+func (s *AParser) IFStatement_ELSE(result *AST_IfStatement) {
+	trace.Println(s.Trace, "IFStatement_ELSE")
 	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.ELSE {
-		s.BlockStatement()
+		result.Else = s.BlockStatement()
 	} else {
 		if token_1.Type == atoken.ELSEIF {
-			if token_2 := s.AToken.GetToken(); token_2.Type == atoken.LP {
-				s.Boolean_Expression()
-				if token_3 := s.AToken.GetToken(); token_3.Type == atoken.RP {
-					s.BlockStatement()
-				} else {
-					//error
-					s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting ')' ->IFStatement_ELSE")
-					os.Exit(1)
-				}
-			} else {
-				//error
-				s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting '(' ->IFStatement_ELSE")
-				os.Exit(1)
+			elseif := new(AST_IfStatement)
+			result.ElseIf = elseif
+			if _, ok := s.expect(atoken.LP, "IFStatement_ELSE"); !ok {
+				return
+			}
+			elseif.Cond = s.Logical_Expression()
+			if _, ok := s.expect(atoken.RP, "IFStatement_ELSE"); ok {
+				elseif.Then = s.BlockStatement()
 			}
 		} else {
 			s.AToken.BackToken()
@@ -341,85 +340,83 @@ func (s *AParser) IFStatement_ELSE() {
 }
 
 //变量声明语句
-func (s *AParser) VarStatement() {
-	fmt.Println("VarStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.VAR {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.WORD {
-			if s.AToken.GetToken().Type == atoken.CASTING { //var fuck -> type
-				if token_3 := s.AToken.GetToken(); token_3.Type == atoken.WORD {
-
-				} else {
-					//error
-					s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting 'Type' ->VarStatement")
-					os.Exit(1)
-				}
-			} else { //var fuck = xxx
-				s.AToken.BackToken()
-				s.AToken.BackToken()
-				s.AssigmentStatement()
-			}
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting 'WORD' ->IFStatement_ELSE")
-			os.Exit(1)
-		}
-	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'var' ->VarStatement")
-		os.Exit(1)
+func (s *AParser) VarStatement(mods DeclModifiers) *AST_VarStatement {
+	trace.Println(s.Trace, "VarStatement")
+	result := new(AST_VarStatement)
+	result.Modifiers = mods
+	if _, ok := s.expect(atoken.VAR, "VarStatement"); !ok {
+		return result
+	}
+	name_token, ok := s.expect(atoken.WORD, "VarStatement")
+	if !ok {
+		return result
+	}
+	result.Name = name_token.Value
+	if s.AToken.GetToken().Type == atoken.CASTING { //var fuck -> type
+		s.expect(atoken.WORD, "VarStatement")
+	} else { //var fuck = xxx
+		s.AToken.BackToken()
+		s.AToken.BackToken()
+		result.Assign = s.AssigmentStatement()
 	}
+	return result
 }
 
 //块语句
-func (s *AParser) BlockStatement() {
-	fmt.Println("BlockStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.LBRACE {
-		s.BlockMain_Statement()
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.RBRACE {
-			//dosth
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting '}' ->BlockStatement")
-			os.Exit(1)
-		}
-	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting '{' ->BlockStatement")
-		os.Exit(1)
+func (s *AParser) BlockStatement() *AST_Block {
+	trace.Println(s.Trace, "BlockStatement")
+	result := new(AST_Block)
+	if _, ok := s.expect(atoken.LBRACE, "BlockStatement"); !ok {
+		return result
 	}
+	s.BlockMain_Statement(result)
+	s.expect(atoken.RBRACE, "BlockStatement")
+	return result
 }
 
-func (s *AParser) BlockMain_Statement() {
-	fmt.Println("BlockStatement_Factor")
-	s.BlockStatement_Factor()
-	s.TMP_BlockMain_Statement()
+func (s *AParser) BlockMain_Statement(result *AST_Block) {
+	trace.Println(s.Trace, "BlockStatement_Factor")
+	if stmt, _ := s.BlockStatement_Factor(); stmt != nil {
+		result.Statements = append(result.Statements, stmt)
+	}
+	s.TMP_BlockMain_Statement(result)
 }
 
-func (s *AParser) TMP_BlockMain_Statement() {
-	fmt.Println("BlockStatement_Factor")
+func (s *AParser) TMP_BlockMain_Statement(result *AST_Block) {
+	trace.Println(s.Trace, "BlockStatement_Factor")
 	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.EOF {
-		fmt.Println("MyToken:[Type:", token_1.Type, "]", "[Value:", token_1.Value, "]->TMP_BlockMain_Statement")
-		if s.BlockStatement_Factor() {
-			s.TMP_BlockMain_Statement()
+		trace.Println(s.Trace, "MyToken:[Type:", token_1.Type, "]", "[Value:", token_1.Value, "]->TMP_BlockMain_Statement")
+		if stmt, ok := s.BlockStatement_Factor(); ok {
+			if stmt != nil {
+				result.Statements = append(result.Statements, stmt)
+			}
+			s.TMP_BlockMain_Statement(result)
 		} else {
 			return
 		}
 
 	} else {
-		fmt.Println("MyToken:[Type:", token_1.Type, "]", "[Value:", token_1.Value, "]->TMP_BlockMain_Statement")
+		trace.Println(s.Trace, "MyToken:[Type:", token_1.Type, "]", "[Value:", token_1.Value, "]->TMP_BlockMain_Statement")
 		s.AToken.BackToken()
 	}
 }
 
-func (s *AParser) BlockStatement_Factor() bool {
-	fmt.Println("BlockStatement_Factor")
+func (s *AParser) BlockStatement_Factor() (*AST_Statement, bool) {
+	trace.Println(s.Trace, "BlockStatement_Factor")
 	token := s.AToken.GetToken()
-	fmt.Println("MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->BlockStatement_Factor")
+	trace.Println(s.Trace, "MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->BlockStatement_Factor")
 	s.AToken.BackToken()
 	switch token.Type {
+	case atoken.STATIC, atoken.EXTERN, atoken.GHOST:
+		mods := s.parseDeclModifiers(true)
+		if _, ok := s.expect(atoken.VAR, "BlockStatement_Factor"); !ok {
+			return nil, false
+		}
+		s.AToken.BackToken()
+		return &AST_Statement{Kind: STMT_VAR, Var: s.VarStatement(mods)}, true
+
 	case atoken.VAR:
-		s.VarStatement()
-		break
+		return &AST_Statement{Kind: STMT_VAR, Var: s.VarStatement(DeclModifiers{})}, true
 
 	case atoken.WORD:
 		s.AToken.GetToken()
@@ -427,311 +424,324 @@ func (s *AParser) BlockStatement_Factor() bool {
 		if s_token.Type == atoken.LP { //<CallFuncStatement>
 			s.AToken.BackToken()
 			s.AToken.BackToken()
-			s.CallFuncStatement()
+			return &AST_Statement{Kind: STMT_CALL, Call: s.CallFuncStatement()}, true
 		} else if s_token.Type == atoken.ASSIGMENT || s_token.Type == atoken.COMMA {
 			s.AToken.BackToken()
 			s.AToken.BackToken()
-			s.AssigmentStatement()
+			return &AST_Statement{Kind: STMT_ASSIGN, Assign: s.AssigmentStatement()}, true
 		}
-		break
+		return nil, true
 
 	case atoken.EOF:
-
-		break
+		return nil, true
 
 	case atoken.FOR:
-		s.ForStatement()
-		break
+		return &AST_Statement{Kind: STMT_FOR, For: s.ForStatement()}, true
 
 	case atoken.IF:
-		s.IFStatement()
-		break
+		return &AST_Statement{Kind: STMT_IF, If: s.IFStatement()}, true
 
 	case atoken.RBRACE:
-		return false
-		break
+		return nil, false
+
 	case atoken.RETURN:
-		s.FuncStatement_Return()
-		break
+		return &AST_Statement{Kind: STMT_RETURN, Return: s.FuncStatement_Return()}, true
 
 	case atoken.CONTINUE:
 		s.ContinueStatement()
-		break
+		return &AST_Statement{Kind: STMT_CONTINUE, Continue: true}, true
 
 	case atoken.BREAK:
 		s.BreakStatement()
-		break
+		return &AST_Statement{Kind: STMT_BREAK, Break: true}, true
+
 	default:
 		s.PushError(token.Line, token.Column, s.File, "unexpected "+token.Value+" expecting 'func' || '=' || 'var' || '( ' || 'WORD' || '\r\n' || '\n' || 'for' ->BlockStatement_Factor")
-		os.Exit(1)
+		s.Synchronize()
 		//error
-		//fmt.Println("error:unexpected")
-		break
+		//trace.Println(s.Trace, "error:unexpected")
+		return nil, true
 	}
-	return true
 }
 
 //函数调用语句
-func (s *AParser) CallFuncStatement() {
-	fmt.Println("CallFuncStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.WORD {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.LP {
-			if token_4 := s.AToken.GetToken(); token_4.Type == atoken.RP {
-				return
-			} else {
-				s.AToken.BackToken()
-			}
-			s.CallFuncStatement_Arg()
-			if token_3 := s.AToken.GetToken(); token_3.Type == atoken.RP {
-				//dosth
-			} else {
-				//error
-				s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting '}' ->CallFuncStatement")
-				os.Exit(1)
-			}
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting '{' ->CallFuncStatement")
-			os.Exit(1)
-		}
+func (s *AParser) CallFuncStatement() *AST_CallFuncStatement {
+	trace.Println(s.Trace, "CallFuncStatement")
+	result := new(AST_CallFuncStatement)
+	name_token, ok := s.expect(atoken.WORD, "CallFuncStatement")
+	if !ok {
+		return result
+	}
+	result.Name = name_token.Value
+	if _, ok := s.expect(atoken.LP, "CallFuncStatement"); !ok {
+		return result
+	}
+	if token_4 := s.AToken.GetToken(); token_4.Type == atoken.RP {
+		return result
 	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'WORD' ->CallFuncStatement")
-		os.Exit(1)
+		s.AToken.BackToken()
 	}
+	result.Args = s.CallFuncStatement_Arg()
+	s.expect(atoken.RP, "CallFuncStatement")
+	return result
 }
 
-func (s *AParser) CallFuncStatement_Arg() {
-	fmt.Println("CallFuncStatement_Arg")
-	s.Arithmetic_Expression()
-	s.Tmp_CallFuncStatement_Arg()
+func (s *AParser) CallFuncStatement_Arg() []*AST_Arithmetic_Expression {
+	trace.Println(s.Trace, "CallFuncStatement_Arg")
+	result := []*AST_Arithmetic_Expression{s.Arithmetic_Expression()}
+	return s.Tmp_CallFuncStatement_Arg(result)
 }
 
-func (s *AParser) Tmp_CallFuncStatement_Arg() {
-	fmt.Println("Tmp_CallFuncStatement_Arg")
+func (s *AParser) Tmp_CallFuncStatement_Arg(result []*AST_Arithmetic_Expression) []*AST_Arithmetic_Expression {
+	trace.Println(s.Trace, "Tmp_CallFuncStatement_Arg")
 	if s.AToken.GetToken().Type == atoken.COMMA {
-		s.Arithmetic_Expression()
-		s.Tmp_CallFuncStatement_Arg()
+		result = append(result, s.Arithmetic_Expression())
+		return s.Tmp_CallFuncStatement_Arg(result)
 	} else {
 		s.AToken.BackToken() //支持空集
 	}
+	return result
 }
 
 //循环语句
-func (s *AParser) ForStatement() {
-	fmt.Println("ForStatement")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.FOR {
-		if token_2 := s.AToken.GetToken(); token_2.Type == atoken.LP {
-			if token_tmp := s.AToken.GetToken(); token_tmp.Type == atoken.SEMICOLON {
-				//允许ForStatement_Assigment为空
-				s.AToken.BackToken()
-			} else {
-				s.AToken.BackToken()
-				s.ForStatement_Assigment()
-			}
-
-			if token_3 := s.AToken.GetToken(); token_3.Type == atoken.SEMICOLON {
-
-			} else {
-				//error
-				s.PushError(token_3.Line, token_3.Column, s.File, "unexpected "+token_3.Value+" expecting 'WORD' ->ForStatement")
-				os.Exit(1)
-			}
-
-			if token_tmp := s.AToken.GetToken(); token_tmp.Type == atoken.SEMICOLON {
-				//允许Boolean_Expression为空
-				s.AToken.BackToken()
-			} else {
-				s.AToken.BackToken()
-				s.Boolean_Expression()
-			}
+func (s *AParser) ForStatement() *AST_ForStatement {
+	trace.Println(s.Trace, "ForStatement")
+	result := new(AST_ForStatement)
+	if _, ok := s.expect(atoken.FOR, "ForStatement"); !ok {
+		return result
+	}
+	if _, ok := s.expect(atoken.LP, "ForStatement"); !ok {
+		return result
+	}
 
-			if token_4 := s.AToken.GetToken(); token_4.Type == atoken.SEMICOLON {
+	if token_tmp := s.AToken.GetToken(); token_tmp.Type == atoken.SEMICOLON {
+		//允许ForStatement_Assigment为空
+		s.AToken.BackToken()
+	} else {
+		s.AToken.BackToken()
+		result.Init = s.ForStatement_Assigment()
+	}
 
-			} else {
-				//error
-				s.PushError(token_4.Line, token_4.Column, s.File, "unexpected "+token_4.Value+" expecting 'WORD' ->ForStatement")
-				os.Exit(1)
-			}
+	s.expect(atoken.SEMICOLON, "ForStatement")
 
-			if token_tmp := s.AToken.GetToken(); token_tmp.Type == atoken.RP {
-				//允许ForStatement_Assigment为空
-				s.AToken.BackToken()
-			} else {
-				s.AToken.BackToken()
-				s.ForStatement_Assigment()
-			}
+	if token_tmp := s.AToken.GetToken(); token_tmp.Type == atoken.SEMICOLON {
+		//允许条件为空，相当于恒真
+		s.AToken.BackToken()
+	} else {
+		s.AToken.BackToken()
+		result.Cond = s.Logical_Expression()
+	}
 
-			if token_5 := s.AToken.GetToken(); token_5.Type == atoken.RP {
-				s.BlockStatement()
-			} else {
-				s.PushError(token_5.Line, token_5.Column, s.File, "unexpected "+token_5.Value+" expecting ')' ->ForStatement")
-				os.Exit(1)
-			}
+	s.expect(atoken.SEMICOLON, "ForStatement")
 
-		} else {
-			//error
-			s.PushError(token_2.Line, token_2.Column, s.File, "unexpected "+token_2.Value+" expecting 'WORD' ->ForStatement")
-			os.Exit(1)
-		}
+	if token_tmp := s.AToken.GetToken(); token_tmp.Type == atoken.RP {
+		//允许ForStatement_Assigment为空
+		s.AToken.BackToken()
 	} else {
-		//error
-		s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting 'for' ->ForStatement")
-		os.Exit(1)
+		s.AToken.BackToken()
+		result.Post = s.ForStatement_Assigment()
 	}
+
+	if _, ok := s.expect(atoken.RP, "ForStatement"); ok {
+		result.Body = s.BlockStatement()
+	}
+	return result
 }
 
-func (s *AParser) ForStatement_Assigment() {
-	fmt.Println("ForStatement_Assigment")
+func (s *AParser) ForStatement_Assigment() *AST_Statement {
+	trace.Println(s.Trace, "ForStatement_Assigment")
 	if s.AToken.GetToken().Type == atoken.VAR {
 		s.AToken.BackToken()
-		s.VarStatement()
-	} else {
+		return &AST_Statement{Kind: STMT_VAR, Var: s.VarStatement(DeclModifiers{})}
+	}
+	s.AToken.BackToken()
+	return &AST_Statement{Kind: STMT_ASSIGN, Assign: s.AssigmentStatement()}
+}
+
+//Conditional_Expression是表达式里结合优先级最低的一层：cond ? a : b，
+//没有'?'就直接退化成Logical_Expression
+func (s *AParser) Conditional_Expression() *AST_Conditional_Expression {
+	trace.Println(s.Trace, "Conditional_Expression")
+	result := new(AST_Conditional_Expression)
+	result.CondExpr = s.Logical_Expression()
+
+	token := s.AToken.GetToken()
+	if token.Type != atoken.QUESTION {
 		s.AToken.BackToken()
-		s.AssigmentStatement()
+		return result
 	}
+	result.Trivia = token.Leading
+	result.TrueExpr = s.Arithmetic_Expression()
+	s.expect(atoken.COLON, "Conditional_Expression")
+	result.FalseExpr = s.Arithmetic_Expression()
+	return result
 }
 
-//判断表达式
-func (s *AParser) Boolean_Expression() {
-	fmt.Println("Boolean_Expression")
-	s.Boolean_Expression_Factor()
+//Logical_Expression处理&&/||链起来的一串操作数，优先级低于比较但高于三元
+func (s *AParser) Logical_Expression() *AST_Logical_Expression {
+	trace.Println(s.Trace, "Logical_Expression")
+	result := new(AST_Logical_Expression)
+	s.logicalOperand(result)
+	tmp_result := result
 	for {
 		token := s.AToken.GetToken()
-		if token.Type != atoken.ALSO &&
-			token.Type != atoken.PERHAPS {
+		if token.Type != atoken.ALSO && token.Type != atoken.PERHAPS {
 			s.AToken.BackToken()
 			break
 		}
-		s.Boolean_Expression_Factor()
 		if token.Type == atoken.ALSO {
-
-		} else if token.Type == atoken.PERHAPS {
-
+			tmp_result.Type = LAND
 		} else {
-			s.AToken.BackToken()
+			tmp_result.Type = LOR
 		}
+		tmp_result.Trivia = token.Leading
+		exp := new(AST_Logical_Expression)
+		s.logicalOperand(exp)
+		tmp_result.Value_Exp = exp
+		tmp_result = exp
 	}
+	return result
 }
 
-func (s *AParser) Boolean_Expression_Factor() {
-	fmt.Println("Boolean_Expression_Factor")
-	if token_1 := s.AToken.GetToken(); token_1.Type == atoken.LP {
-		fmt.Println("Boolean_Expression_Factor")
-		s.Boolean_Expression()
-		if s.AToken.GetToken().Type == atoken.RP {
-
-		} else {
-			//error
-			s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting ')' ->Boolean_Expression_Factor")
-			os.Exit(1)
-		}
+//logicalOperand把result填成Logical_Expression链条里的一个操作数：可选的一元!，
+//后面跟着一个带括号的Logical_Expression分组或者一次Comparison_Expression
+func (s *AParser) logicalOperand(result *AST_Logical_Expression) {
+	if token := s.AToken.GetToken(); token.Type == atoken.NOT {
+		result.Not = true
 	} else {
 		s.AToken.BackToken()
-		s.Arithmetic_Expression()
-		s_token := s.AToken.GetToken()
-		if s_token.Type == atoken.GT {
-			s.Arithmetic_Expression()
-
-		} else if s_token.Type == atoken.LT {
-			s.Arithmetic_Expression()
+	}
 
-		} else if s_token.Type == atoken.GTEQ {
-			s.Arithmetic_Expression()
+	if token := s.AToken.GetToken(); token.Type == atoken.LP {
+		result.IsGroup = true
+		result.Group = s.Logical_Expression()
+		s.expect(atoken.RP, "logicalOperand")
+		return
+	}
+	s.AToken.BackToken()
+	result.Value_Cmp = s.Comparison_Expression()
+}
 
-		} else if s_token.Type == atoken.LTEQ {
-			s.Arithmetic_Expression()
+//Comparison_Expression处理两个算术表达式之间最多一次的比较，优先级低于+/-，
+//高于&&/||。没有比较运算符就直接把Left原样当成这个操作数的值(比如bool变量)
+func (s *AParser) Comparison_Expression() *AST_Comparison_Expression {
+	trace.Println(s.Trace, "Comparison_Expression")
+	result := new(AST_Comparison_Expression)
+	result.Left = s.Arithmetic_Expression()
 
-		} else {
-			//error
-			s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting '(' || '>' || '<' || '>=' || '<=' ->Boolean_Expression_Factor")
-			os.Exit(1)
-		}
+	token := s.AToken.GetToken()
+	switch token.Type {
+	case atoken.LT:
+		result.Type = LT
+	case atoken.LTEQ:
+		result.Type = LTE
+	case atoken.GT:
+		result.Type = GT
+	case atoken.GTEQ:
+		result.Type = GTE
+	case atoken.EQ:
+		result.Type = EQ
+	case atoken.NOEQ:
+		result.Type = NEQ
+	default:
+		s.AToken.BackToken()
+		return result
 	}
+	result.Trivia = token.Leading
+	result.Right = s.Arithmetic_Expression()
+	return result
 }
 
 //基本表达式
-func (s *AParser) Arithmetic_Expression() * AST_Arithmetic_Expression{
-	result := new(AST_Arithmetic_Expression)
-	fmt.Println("Arithmetic_Expression")
-	result.Value_Term = s.Arithmetic_Expression_Term()
-	tmp_result := result
-	for {
-		token := s.AToken.GetToken()
-		fmt.Println("MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Arithmetic_Expression")
-		if token.Type != atoken.ADD &&
-			token.Type != atoken.SUB {
-			s.AToken.BackToken()
-			break
-		}
-		term := s.Arithmetic_Expression_Term()
-		if token.Type == atoken.ADD {
-			tmp_result.Type = ADD
-		} else if token.Type == atoken.SUB {
-			tmp_result.Type = SUB
-		} else {
-			s.AToken.BackToken()
-			continue
-		}
-		exp := new(AST_Arithmetic_Expression)
-		exp.Value_Term = term
-		tmp_result.Value_Exp = exp
-		tmp_result = exp 
+//operatorPriority是precedence-climbing用的优先级表，数字越大绑定越紧。
+//POWER单独占最高一档，MUL/DIV/MOD/RAND同一档，ADD/SUB最低档。原来
+//Expression/Term两层手写递归里POWER和MUL/DIV/MOD/RAND同在Term一层，现在
+//它单独高出一档，所以2*3^4会先算3^4；新增一个算术运算符只需要在这一张表里
+//加一行，不用再多写一层产生式函数
+var operatorPriority = map[int]int{
+	ADD:   1,
+	SUB:   1,
+	MUL:   2,
+	DIV:   2,
+	MOD:   2,
+	RAND:  2,
+	POWER: 3,
+}
+
+//arithmeticOp把词法层的运算符token翻译成aparser自己的Op常量，不认识的
+//token返回0（和AST_Arithmetic_Expression的叶子节点Op==0共用同一个哨兵值，
+//这里的0永远不会被当成真的运算符使用，调用方只拿它当“不是运算符”的标记）
+func arithmeticOp(tokenType int) int {
+	switch tokenType {
+	case atoken.ADD:
+		return ADD
+	case atoken.SUB:
+		return SUB
+	case atoken.MUL:
+		return MUL
+	case atoken.DIV:
+		return DIV
+	case atoken.POWER:
+		return POWER
+	case atoken.MOD:
+		return MOD
+	case atoken.RAND:
+		return RAND
+	default:
+		return 0
 	}
-	
-	return result
 }
 
-func (s *AParser) Arithmetic_Expression_Term() * AST_Arithmetic_Expression_Term{
-	fmt.Println("Arithmetic_Expression_Term")
-	result := new(AST_Arithmetic_Expression_Term)
-	
-	result.Value_Factor = s.Arithmetic_Expression_Factor()
-	tmp_result := result
+func (s *AParser) Arithmetic_Expression() *AST_Arithmetic_Expression {
+	trace.Println(s.Trace, "Arithmetic_Expression")
+	return s.parseExpr(1)
+}
+
+//parseExpr是一个precedence-climbing(Pratt)解析器：先读一个Factor当作最左边的
+//操作数，然后只要看到的运算符优先级不低于minPrec就把它吃进当前这棵树；递归
+//解析右操作数时minPrec传prec+1，这样同一优先级的运算符会在当前这一层被
+//continue吃掉(左结合)而不是递归到右边(右结合)，比如1-2-3会被解析成(1-2)-3
+func (s *AParser) parseExpr(minPrec int) *AST_Arithmetic_Expression {
+	left := new(AST_Arithmetic_Expression)
+	left.Factor = s.Arithmetic_Expression_Factor()
+
 	for {
 		token := s.AToken.GetToken()
-		fmt.Println("MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Arithmetic_Expression_Term")
-		if token.Type != atoken.MUL &&
-			token.Type != atoken.DIV &&
-			token.Type != atoken.POWER &&
-			token.Type != atoken.MOD &&
-			token.Type != atoken.RAND {
+		trace.Println(s.Trace, "MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->parseExpr")
+		op := arithmeticOp(token.Type)
+		prec, ok := operatorPriority[op]
+		if !ok || prec < minPrec {
 			s.AToken.BackToken()
 			break
 		}
-		factor := s.Arithmetic_Expression_Factor()
-		if token.Type == atoken.MUL {
-			tmp_result.Type = MUL
-		} else if token.Type == atoken.DIV {
-			tmp_result.Type = DIV
-		} else if token.Type == atoken.POWER {
-			tmp_result.Type = POWER
-		} else if token.Type == atoken.MOD {
-			tmp_result.Type = MOD
-		} else if token.Type == atoken.RAND {
-			tmp_result.Type = RAND
-		} else {
-			s.AToken.BackToken()
-			continue
-		}
-		term := new(AST_Arithmetic_Expression_Term)
-		term.Value_Factor = factor
-		tmp_result.Value_Term = term
-		tmp_result = term
+
+		right := s.parseExpr(prec + 1)
+
+		node := new(AST_Arithmetic_Expression)
+		node.Op = op
+		node.Left = left
+		node.Right = right
+		node.Trivia = token.Leading
+		node.registry = s.Operators
+		left = node
 	}
-	
-	return result
+
+	return left
 }
 
 func (s *AParser) Arithmetic_Expression_Factor() *AST_Arithmetic_Expression_Factor {
 	result := new(AST_Arithmetic_Expression_Factor)
 
-	fmt.Println("Arithmetic_Expression_Factor")
+	trace.Println(s.Trace, "Arithmetic_Expression_Factor")
 	token := s.AToken.GetToken()
-	result.Line = token.Line
-	fmt.Println("MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Arithmetic_Expression_Factor")
-	if token.Type == atoken.NUMBER {
-		//转换成数字
+	result.Span.File = s.File
+	result.Span.StartLine = token.Line
+	result.Span.StartCol = token.Column
+	result.Trivia = token.Leading
+	trace.Println(s.Trace, "MyToken:[Type:", token.Type, "]", "[Value:", token.Value, "]->Arithmetic_Expression_Factor")
+	if token.Type == atoken.NUMBER || token.Type == atoken.INT || token.Type == atoken.INT64 {
+		//转换成数字，INT/INT64/NUMBER只是词法阶段按字面量大小/形式分的token种类，
+		//到了AST这一层统一当作Number处理
 		result.Type = NUMBER
 		result.Value_Number = New_ASTNumber(token.Value)
 	} else if token.Type == atoken.TRUE {
@@ -742,17 +752,15 @@ func (s *AParser) Arithmetic_Expression_Factor() *AST_Arithmetic_Expression_Fact
 		//转换成布尔值-false
 		result.Type = BOOL
 		result.Value_Bool = false
+	} else if token.Type == atoken.STRING {
+		//字符串字面量，包括"..."和<<<LBL ... LBL两种形式的原始内容
+		result.Type = STRING
+		result.Value_String = s.parseStringLiteral(token.Value)
 	} else if token.Type == atoken.LP {
 		//表达式
 		result.Type = ARITHMETICEXPRESSION
 		result.Value_Arithmetic_Expression = s.Arithmetic_Expression()
-		if token_1 := s.AToken.GetToken(); token_1.Type == atoken.RP {
-
-		} else {
-			s.PushError(token_1.Line, token_1.Column, s.File, "unexpected "+token_1.Value+" expecting '}' ->Arithmetic_Expression_Factor")
-			os.Exit(1)
-			//error
-		}
+		s.expect(atoken.RP, "Arithmetic_Expression_Factor")
 	} else if token.Type == atoken.WORD {
 		if token_1 := s.AToken.GetToken(); token_1.Type == atoken.ADDSELF {
 			//自增
@@ -767,7 +775,7 @@ func (s *AParser) Arithmetic_Expression_Factor() *AST_Arithmetic_Expression_Fact
 			s.AToken.BackToken()
 			s.AToken.BackToken()
 			result.Type = CALLFUNC
-			s.CallFuncStatement()
+			result.Value_CallFunc = s.CallFuncStatement()
 		} else {
 			//单一Var
 			result.Type = VAR
@@ -776,7 +784,15 @@ func (s *AParser) Arithmetic_Expression_Factor() *AST_Arithmetic_Expression_Fact
 		}
 	} else {
 		s.PushError(token.Line, token.Column, s.File, "unexpected "+token.Value+" expecting 'num' || 'WORD' || 'true' || 'false' || ->Arithmetic_Expression_Factor")
-		os.Exit(1)
+		s.Synchronize()
+	}
+
+	if end := s.AToken.Peek(-1); end != nil {
+		result.Span.EndLine = end.Line
+		result.Span.EndCol = end.Column + len([]rune(end.Value))
+	} else {
+		result.Span.EndLine = result.Span.StartLine
+		result.Span.EndCol = result.Span.StartCol
 	}
 
 	return result
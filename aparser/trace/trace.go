@@ -0,0 +1,16 @@
+//Package trace holds the parser's production-entry debug logging. It used to be
+//a `fmt.Println("ProductionName")` dropped inline at the top of every AParser
+//method; pulling it out means that chatter can be switched off for embedders
+//that don't care about it instead of always printing to stdout.
+package trace
+
+import "fmt"
+
+//Println prints args exactly like fmt.Println when enabled is true, and is a
+//no-op otherwise. Callers pass their own AParser.Trace flag as enabled.
+func Println(enabled bool, args ...interface{}) {
+	if !enabled {
+		return
+	}
+	fmt.Println(args...)
+}
@@ -0,0 +1,273 @@
+//Package aprinter walks the AST produced by aparser and prints it back as
+//source-like text. It is the first consumer of the AST_Statement tree
+//that every statement production now builds -- previously only
+//Arithmetic_Expression built real nodes and every other production just
+//printed trace output and threw its result away.
+//
+//The output is an outline, not source that parses back to the same program:
+//if and elseif conditions are printed as "(...)", a for header holds only its
+//init and post clauses (the loop condition is left out), and function
+//parameters, return types, var type annotations and static/extern/ghost
+//modifiers are not printed.
+package aprinter
+
+import (
+	"aparser"
+	"bytes"
+	"fmt"
+)
+
+//opSymbol maps the aparser-internal binary operator codes (AST_Arithmetic_Expression.Op)
+//back to their surface syntax.
+var opSymbol = map[int]string{
+	aparser.ADD:   "+",
+	aparser.SUB:   "-",
+	aparser.MUL:   "*",
+	aparser.DIV:   "/",
+	aparser.POWER: "^",
+	aparser.MOD:   "%",
+	aparser.RAND:  "~",
+}
+
+//Print returns the outline text (see the package doc) for a top-level block produced by
+//AParser.ReadString/ReadFile (AParser.Program).
+func Print(block *aparser.AST_Block) string {
+	p := new(printer)
+	p.block(block, 0)
+	return p.buf.String()
+}
+
+type printer struct {
+	buf bytes.Buffer
+}
+
+func (p *printer) indent(depth int) {
+	for i := 0; i < depth; i++ {
+		p.buf.WriteString("\t")
+	}
+}
+
+func (p *printer) block(block *aparser.AST_Block, depth int) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		p.statement(stmt, depth)
+	}
+}
+
+func (p *printer) statement(stmt *aparser.AST_Statement, depth int) {
+	if stmt == nil {
+		return
+	}
+	p.indent(depth)
+	switch stmt.Kind {
+	case aparser.STMT_FUNC:
+		p.funcStatement(stmt.Func, depth)
+	case aparser.STMT_VAR:
+		p.varStatement(stmt.Var)
+		p.buf.WriteString("\n")
+	case aparser.STMT_ASSIGN:
+		p.assignStatement(stmt.Assign)
+		p.buf.WriteString("\n")
+	case aparser.STMT_BLOCK:
+		p.buf.WriteString("{\n")
+		p.block(stmt.Block, depth+1)
+		p.indent(depth)
+		p.buf.WriteString("}\n")
+	case aparser.STMT_CALL:
+		p.callStatement(stmt.Call)
+		p.buf.WriteString("\n")
+	case aparser.STMT_FOR:
+		p.forStatement(stmt.For, depth)
+	case aparser.STMT_IF:
+		p.ifStatement(stmt.If, depth)
+	case aparser.STMT_RETURN:
+		p.returnStatement(stmt.Return)
+		p.buf.WriteString("\n")
+	case aparser.STMT_BREAK:
+		p.buf.WriteString("break\n")
+	case aparser.STMT_CONTINUE:
+		p.buf.WriteString("continue\n")
+	}
+}
+
+func (p *printer) funcStatement(stmt *aparser.AST_FuncStatement, depth int) {
+	if stmt == nil {
+		return
+	}
+	fmt.Fprintf(&p.buf, "func %s() {\n", stmt.Name)
+	p.block(stmt.Body, depth+1)
+	p.indent(depth)
+	p.buf.WriteString("}\n")
+}
+
+func (p *printer) varStatement(stmt *aparser.AST_VarStatement) {
+	if stmt == nil {
+		return
+	}
+	if stmt.Assign != nil {
+		p.buf.WriteString("var ")
+		p.assignStatement(stmt.Assign)
+		return
+	}
+	fmt.Fprintf(&p.buf, "var %s", stmt.Name)
+}
+
+func (p *printer) assignStatement(stmt *aparser.AST_AssigmentStatement) {
+	if stmt == nil {
+		return
+	}
+	for i, name := range stmt.Names {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.buf.WriteString(name)
+	}
+	if len(stmt.Values) == 0 {
+		return
+	}
+	p.buf.WriteString(" = ")
+	for i, value := range stmt.Values {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.expression(value)
+	}
+}
+
+func (p *printer) callStatement(stmt *aparser.AST_CallFuncStatement) {
+	if stmt == nil {
+		return
+	}
+	fmt.Fprintf(&p.buf, "%s(", stmt.Name)
+	for i, arg := range stmt.Args {
+		if i > 0 {
+			p.buf.WriteString(", ")
+		}
+		p.expression(arg)
+	}
+	p.buf.WriteString(")")
+}
+
+func (p *printer) forStatement(stmt *aparser.AST_ForStatement, depth int) {
+	if stmt == nil {
+		return
+	}
+	p.buf.WriteString("for(")
+	for i, clause := range []*aparser.AST_Statement{stmt.Init, stmt.Post} {
+		if i > 0 {
+			p.buf.WriteString(";")
+		}
+		if clause != nil && clause.Kind == aparser.STMT_VAR {
+			p.varStatement(clause.Var)
+		} else if clause != nil {
+			p.assignStatement(clause.Assign)
+		}
+	}
+	p.buf.WriteString(") {\n")
+	p.block(stmt.Body, depth+1)
+	p.indent(depth)
+	p.buf.WriteString("}\n")
+}
+
+func (p *printer) ifStatement(stmt *aparser.AST_IfStatement, depth int) {
+	if stmt == nil {
+		return
+	}
+	p.buf.WriteString("if(...) {\n")
+	p.block(stmt.Then, depth+1)
+	p.indent(depth)
+	p.buf.WriteString("}")
+	if stmt.ElseIf != nil {
+		p.buf.WriteString(" else")
+		p.ifStatement(stmt.ElseIf, depth)
+		return
+	}
+	if stmt.Else != nil {
+		p.buf.WriteString(" else {\n")
+		p.block(stmt.Else, depth+1)
+		p.indent(depth)
+		p.buf.WriteString("}\n")
+		return
+	}
+	p.buf.WriteString("\n")
+}
+
+func (p *printer) returnStatement(stmt *aparser.AST_ReturnStatement) {
+	if stmt == nil {
+		return
+	}
+	p.buf.WriteString("return")
+	for i, value := range stmt.Values {
+		if i == 0 {
+			p.buf.WriteString(" ")
+		} else {
+			p.buf.WriteString(", ")
+		}
+		p.expression(value)
+	}
+}
+
+func (p *printer) expression(expr *aparser.AST_Arithmetic_Expression) {
+	if expr == nil {
+		return
+	}
+	if expr.Op == 0 {
+		p.factor(expr.Factor)
+		return
+	}
+	p.expression(expr.Left)
+	fmt.Fprintf(&p.buf, " %s ", opSymbol[expr.Op])
+	p.expression(expr.Right)
+}
+
+func (p *printer) factor(factor *aparser.AST_Arithmetic_Expression_Factor) {
+	if factor == nil {
+		return
+	}
+	switch factor.Type {
+	case aparser.NUMBER:
+		if digits, exact := factor.Value_Number.FloatPrec(); exact {
+			p.buf.WriteString(factor.Value_Number.FloatString(digits))
+		} else {
+			fmt.Fprintf(&p.buf, "(%s)", factor.Value_Number.RatString())
+		}
+	case aparser.BOOL:
+		fmt.Fprintf(&p.buf, "%t", factor.Value_Bool)
+	case aparser.STRING:
+		p.stringLiteral(factor.Value_String)
+	case aparser.VAR, aparser.SELFOPERATION_ADDSELF, aparser.SELFOPERATION_SUBSELF:
+		p.buf.WriteString(factor.Value_VarWord)
+		if factor.Type == aparser.SELFOPERATION_ADDSELF {
+			p.buf.WriteString("++")
+		} else if factor.Type == aparser.SELFOPERATION_SUBSELF {
+			p.buf.WriteString("--")
+		}
+	case aparser.CALLFUNC:
+		p.callStatement(factor.Value_CallFunc)
+	case aparser.ARITHMETICEXPRESSION:
+		p.buf.WriteString("(")
+		p.expression(factor.Value_Arithmetic_Expression)
+		p.buf.WriteString(")")
+	}
+}
+
+func (p *printer) stringLiteral(lit *aparser.AST_StringLiteral) {
+	p.buf.WriteString("\"")
+	if lit != nil {
+		for _, part := range lit.Parts {
+			switch part.Kind {
+			case aparser.StringPartRaw:
+				p.buf.WriteString(part.Raw)
+			case aparser.StringPartVarRef:
+				fmt.Fprintf(&p.buf, "$%s", part.VarRef)
+			case aparser.StringPartExpr:
+				p.buf.WriteString("${")
+				p.expression(part.Expr)
+				p.buf.WriteString("}")
+			}
+		}
+	}
+	p.buf.WriteString("\"")
+}
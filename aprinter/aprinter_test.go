@@ -0,0 +1,32 @@
+package aprinter
+
+import (
+	"aparser"
+	"strings"
+	"testing"
+)
+
+func Test_Print(t *testing.T) {
+	parser := aparser.New()
+	diagnostics := parser.ReadString(`
+	var fuck = 10
+
+	func fucker(var wokao -> int) -> int{
+		return 1
+	}
+
+	fuck = 100+2*3
+	`)
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %d: %v", len(diagnostics), diagnostics[0].Error())
+	}
+
+	out := Print(parser.Program)
+	if !strings.Contains(out, "var fuck") {
+		t.Fatalf("expected output to contain the var statement, got:\n%s", out)
+	}
+	if !strings.Contains(out, "func fucker()") {
+		t.Fatalf("expected output to contain the func statement, got:\n%s", out)
+	}
+}
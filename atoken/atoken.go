@@ -1,25 +1,96 @@
 package atoken
 
 import (
+	"bufio"
 	"container/list"
 	"fmt"
-	"os"
+	"io"
+	"math"
+	"math/big"
+	"strings"
 )
 
 type AToken struct {
 	Line   int    //行
 	Column int    //列
-	Value  string //数据
+	Value  string //数据，字符串/字符字面量保留原始未转义文本
 	Type   int    //类型
+
+	Decoded string //STRING/CHAR字面量转义解码之后的值；其他token类型留空，用Value即可
+
+	Leading  []TriviaToken //挂在这个token前面的空白/注释，供aprinter之类的工具原样回放
+	Trailing []TriviaToken //挂在上一个"真实"token后面、同一行内的空白/注释
+}
+
+//TriviaKind标记一段free-floating trivia的种类
+type TriviaKind int
+
+const (
+	TriviaWhitespace TriviaKind = iota
+	TriviaNewline
+	TriviaLineComment
+	TriviaBlockComment
+)
+
+//TriviaToken是不参与语法的"装饰性"文本——空白、换行、注释。
+//词法阶段不再丢弃它们，而是挂到相邻的AToken上，这样格式化工具可以
+//在不丢信息的前提下原样输出源码。
+type TriviaToken struct {
+	Kind   TriviaKind
+	Value  string
+	Line   int
+	Column int
 }
 
 type ATokenList struct {
-	token_list       *list.List
-	Line             int
-	Column           int
-	Count            int
-	Error_list       *list.List
-	current_iterator *list.Element
+	tokens     []*AToken //词法分析产出的全部token，按序排列，以Value=="End"的EOF哨兵收尾
+	pos        int       //游标：GetToken/Peek(0)下一个要交出的token在tokens里的下标
+	Line       int
+	Column     int
+	Count      int
+	Error_list *list.List
+
+	pendingTrivia []TriviaToken //还没有挂到任何token上的trivia，按序等待下一个真实token
+	lastToken     *AToken       //最近一次push的真实token，用于把同一行的trivia记成它的Trailing
+
+	PreserveComments bool //为true时注释以LINE_COMMENT/BLOCK_COMMENT token形式进入tokens，否则只作为trivia挂在相邻token上
+}
+
+//addTrivia把一段trivia加入等待队列。遇到换行trivia时，先把换行之前
+//积累的trivia结算成lastToken的Trailing（它们和上一个token同一行），
+//换行本身以及之后的trivia留给下一个token当Leading。
+func (s *ATokenList) addTrivia(kind TriviaKind, value string) {
+	if kind == TriviaNewline && s.lastToken != nil && len(s.pendingTrivia) > 0 {
+		s.lastToken.Trailing = append(s.lastToken.Trailing, s.pendingTrivia...)
+		s.pendingTrivia = nil
+	}
+	s.pendingTrivia = append(s.pendingTrivia, TriviaToken{Kind: kind, Value: value, Line: s.Line, Column: s.Column})
+}
+
+//push把一个真实token接到tokens上，并把目前积累的trivia作为它的Leading
+func (s *ATokenList) push(tok *AToken) {
+	tok.Leading = s.pendingTrivia
+	s.pendingTrivia = nil
+	s.tokens = append(s.tokens, tok)
+	s.lastToken = tok
+}
+
+//advance是s.Line/s.Column这两个字段唯一的写入点：每消费一个源码rune就调用
+//一次。之前ReadString在switch末尾用`i-tmp_index+1`估算整个case消费了多少个
+//字符去递增Column，但judge_block_comment/judge_heredoc/judge_raw_string这类
+//会跨行的case自己也会在中途把Column清零——估算值和它们已经写入的真实值互相
+//打架，跨行token之后的Column就不对了。现在这些case改成照着自己实际走过的每
+//个rune调用一次advance，不再各自维护Line/Column。
+//
+//'\r'和'\n'都按"换一行"处理，换行后Column归零；调用方在遇到"\r\n"这种两个
+//rune才算一次换行的场景时，只对其中一个rune调用advance，避免重复计数。
+func (s *ATokenList) advance(r rune) {
+	if r == '\n' || r == '\r' {
+		s.Line++
+		s.Column = 0
+		return
+	}
+	s.Column++
 }
 
 type ALexError struct {
@@ -33,6 +104,12 @@ func (s *ALexError) Error() string {
 	return error_info
 }
 
+//Message返回这条词法错误自身的描述文本，不带Error()那层"Error(Lex)[...]"的
+//前缀，供上游(比如aparser.Diagnostic)用自己的格式重新包装
+func (s *ALexError) Message() string {
+	return s.error_info
+}
+
 func judge_word(data string, index *int, source *[]rune) bool {
 	for i, v := range data {
 		if (*index) < len(*source) && (*source)[*index] == v {
@@ -53,268 +130,577 @@ func judge_symbol(ch rune) bool {
 	return false
 }
 
-func judge_other(index *int, source *[]rune, s *ATokenList) {
+//keywords maps every reserved word to its token type. judge_identifier scans
+//the whole identifier first and then does a single lookup here, instead of
+//branching on the first letter -- that's what used to make "elseif" and
+//"else" (and "switch"/"static", "case"/"continue", ...) fight over the same
+//case and need hand-ordered judge_word chains.
+var keywords = map[string]int{
+	"func":     FUNC,
+	"for":      FOR,
+	"false":    FALSE,
+	"true":     TRUE,
+	"return":   RETURN,
+	"if":       IF,
+	"elseif":   ELSEIF,
+	"else":     ELSE,
+	"extern":   EXTERN,
+	"new":      NEW,
+	"number":   NUMBER,
+	"list":     LIST,
+	"map":      MAP,
+	"var":      VAR,
+	"break":    BREAK,
+	"switch":   SWITCH,
+	"static":   STATIC,
+	"ghost":    GHOST,
+	"case":     CASE,
+	"continue": CONTINUE,
+}
+
+func judge_identifier(index *int, source *[]rune, s *ATokenList) {
 	var tmp []rune
 	for {
 		if (*index) >= len(*source) {
-			value := string(tmp)
-			s.token_list.PushBack(&AToken{s.Line, s.Column, value, WORD})
-			return
+			break
 		}
 
 		if judge_symbol((*source)[*index]) {
 			tmp = append(tmp, (*source)[*index])
 			(*index)++
 		} else {
-			value := string(tmp)
-			s.token_list.PushBack(&AToken{s.Line, s.Column, value, WORD})
 			(*index)--
-			return
+			break
 		}
 	}
+
+	value := string(tmp)
+	tokenType, ok := keywords[value]
+	if !ok {
+		tokenType = WORD
+	}
+	s.push(&AToken{Line: s.Line, Column: s.Column, Value: value, Type: tokenType})
 }
 
-func judge_space(index *int, source *[]rune) {
+func judge_space(index *int, source *[]rune, s *ATokenList) {
+	start := *index
 	for (*index) < len(*source) && ((*source)[*index] == ' ' || (*source)[*index] == '\t' || (*source)[*index] == '\v') {
 		(*index)++
 	}
+	s.addTrivia(TriviaWhitespace, string((*source)[start:*index]))
 	(*index)--
 }
 
+//integerTokenType classifies a base-10 integer literal by magnitude: INT if
+//it fits in 32 bits, INT64 if it needs the full 64, and NUMBER (this
+//language's arbitrary-precision type) if it overflows even that.
+func integerTokenType(decimal string) int {
+	value := new(big.Int)
+	if _, ok := value.SetString(decimal, 10); !ok {
+		return NUMBER
+	}
+	if !value.IsInt64() {
+		return NUMBER
+	}
+	v := value.Int64()
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		return INT
+	}
+	return INT64
+}
+
+//radixDigits maps a 0x/0o/0b prefix letter to its base and the digits that
+//are legal in it.
+var radixDigits = map[rune]struct {
+	base   int
+	digits string
+}{
+	'x': {16, "0123456789abcdefABCDEF"},
+	'X': {16, "0123456789abcdefABCDEF"},
+	'o': {8, "01234567"},
+	'O': {8, "01234567"},
+	'b': {2, "01"},
+	'B': {2, "01"},
+}
+
+//judge_radix_integer lexes a 0x/0o/0b-prefixed integer literal, with *index
+//pointing at the leading '0'. The digits are converted to their decimal
+//string form via big.Int, since that's the only notation AST_Number's
+//big.Rat parser understands -- atoken has no big-number type of its own.
+func judge_radix_integer(index *int, source *[]rune, s *ATokenList, radix rune) {
+	spec := radixDigits[radix]
+	(*index) += 2 // skip the "0x"/"0o"/"0b" prefix
+	digitsStart := *index
+	for *index < len(*source) && strings.ContainsRune(spec.digits, (*source)[*index]) {
+		(*index)++
+	}
+	literal := string((*source)[digitsStart:*index])
+	if literal == "" {
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "illegal number"})
+		s.push(&AToken{Line: s.Line, Column: s.Column, Value: "0", Type: INT})
+		(*index)--
+		return
+	}
+
+	value := new(big.Int)
+	value.SetString(literal, spec.base)
+	decimal := value.String()
+	s.push(&AToken{Line: s.Line, Column: s.Column, Value: decimal, Type: integerTokenType(decimal)})
+	(*index)--
+}
+
+//judge_number lexes a numeric literal: a decimal integer or float (with an
+//optional exponent), or a 0x/0o/0b-prefixed hex/octal/binary integer. Value
+//is always normalized to the plain base-10 digits/decimal-point/exponent
+//text that AST_Number's big.Rat parser expects -- hex/octal/binary literals
+//are converted to their decimal equivalent -- and Type distinguishes an
+//integer that fits in 32 bits (INT), one that needs 64 (INT64), from
+//anything with a decimal point or exponent (NUMBER).
 func judge_number(index *int, source *[]rune, s *ATokenList) {
+	if (*source)[*index] == '0' && *index+1 < len(*source) {
+		if _, ok := radixDigits[(*source)[*index+1]]; ok {
+			judge_radix_integer(index, source, s, (*source)[*index+1])
+			return
+		}
+	}
+
 	tmp := []rune{}
 	dot_count := 0
+	has_exponent := false
 	for {
 		if (*index) >= len(*source) {
 			break
 		}
 
-		if (*source)[*index] >= '0' && (*source)[*index] <= '9' {
-			tmp = append(tmp, (*source)[*index])
-		} else if (*source)[*index] == '.' {
-			if dot_count > 1 {
+		ch := (*source)[*index]
+		if ch >= '0' && ch <= '9' {
+			tmp = append(tmp, ch)
+		} else if ch == '.' {
+			if dot_count >= 1 || has_exponent {
 				s.Error_list.PushBack(&ALexError{s.Line, s.Column, "illegal number"})
 				(*index)--
 				break
 			}
-			tmp = append(tmp, (*source)[*index])
+			tmp = append(tmp, ch)
 			dot_count++
+		} else if (ch == 'e' || ch == 'E') && !has_exponent && *index+1 < len(*source) {
+			sign := ""
+			next := *index + 1
+			if (*source)[next] == '+' || (*source)[next] == '-' {
+				sign = string((*source)[next])
+				next++
+			}
+			if next < len(*source) && (*source)[next] >= '0' && (*source)[next] <= '9' {
+				tmp = append(tmp, 'e')
+				tmp = append(tmp, []rune(sign)...)
+				has_exponent = true
+				(*index) = next - 1
+			} else {
+				(*index)--
+				break
+			}
 		} else {
 			(*index)--
 			break
 		}
 		(*index)++
 	}
-	s.token_list.PushBack(&AToken{s.Line, s.Column, string(tmp), NUMBER})
+
+	numberType := NUMBER
+	if dot_count == 0 && !has_exponent {
+		numberType = integerTokenType(string(tmp))
+	}
+	s.push(&AToken{Line: s.Line, Column: s.Column, Value: string(tmp), Type: numberType})
 }
 
-func judge_string(index *int, source *[]rune, s *ATokenList) {
-	tmp := []rune{}
-	count := 0
-	for {
-		if (*index) >= len(*source) {
-			if count < 2 {
-				s.Error_list.PushBack(&ALexError{s.Line, s.Column, "lose a '\"'"})
-			}
-			break
+//decodeEscapeSequence decodes a single backslash escape, with *index pointing
+//at the character right after the '\\' (the escape letter itself). It
+//appends every source rune it consumes -- the escape letter and, for \x/\u,
+//the hex digits that follow -- onto raw, leaves *index on the last of them
+//(the caller steps past it), and returns the decoded rune. An escape it doesn't recognize, or a malformed
+//\x/\u, is reported as a lex error; the escape letter is returned unchanged
+//so the decoded text still has something to show for it.
+func decodeEscapeSequence(index *int, source *[]rune, s *ATokenList, raw *[]rune) rune {
+	if *index >= len(*source) {
+		return '\\'
+	}
+	ch := (*source)[*index]
+	*raw = append(*raw, ch)
+	switch ch {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	case '\\', '"', '\'', '$', '`':
+		return ch
+	case 'x':
+		if v, ok := consumeHexEscape(index, source, raw, 2); ok {
+			return rune(v)
+		}
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "invalid \\x escape, expected 2 hex digits"})
+		return 'x'
+	case 'u':
+		if v, ok := consumeHexEscape(index, source, raw, 4); ok {
+			return rune(v)
 		}
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "invalid \\u escape, expected 4 hex digits"})
+		return 'u'
+	default:
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "unknown escape sequence '\\" + string(ch) + "'"})
+		return ch
+	}
+}
 
-		if count >= 2 {
-			(*index)--
-			break
+//consumeHexEscape reads the n hex digits following a \x or \u escape letter
+//(*index still points at that letter), appends them to raw and moves
+//*index onto the last of them. It reports ok=false, without touching *index or raw, if
+//fewer than n hex digits are available.
+func consumeHexEscape(index *int, source *[]rune, raw *[]rune, n int) (int, bool) {
+	v := 0
+	for i := 1; i <= n; i++ {
+		if *index+i >= len(*source) {
+			return 0, false
+		}
+		c := (*source)[*index+i]
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			d = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			d = int(c-'A') + 10
+		default:
+			return 0, false
 		}
+		v = v*16 + d
+	}
+	for i := 1; i <= n; i++ {
+		*raw = append(*raw, (*source)[*index+i])
+	}
+	*index += n
+	return v, true
+}
 
+//judge_string lexes a "..." string literal, starting at the opening '"'.
+//Value keeps the raw, unescaped source text -- so aparser's $name/${expr}
+//interpolation scan (added for heredocs) still sees literal backslashes and
+//dollar signs -- while Decoded resolves every escape sequence (\n, \t, \\,
+//\xHH, \uHHHH, ...) into the literal's actual runtime value.
+func judge_string(index *int, source *[]rune, s *ATokenList) {
+	raw := []rune{}
+	decoded := []rune{}
+	(*index)++
+	closed := false
+	for *index < len(*source) {
 		if (*source)[*index] == '"' {
-			count++
+			closed = true
+			break
+		}
+
+		if (*source)[*index] == '\\' {
+			raw = append(raw, '\\')
+			(*index)++
+			decoded = append(decoded, decodeEscapeSequence(index, source, s, &raw))
 			(*index)++
 			continue
 		}
 
+		raw = append(raw, (*source)[*index])
+		decoded = append(decoded, (*source)[*index])
+		(*index)++
+	}
+	if !closed {
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "lose a '\"'"})
+		(*index)--
+	}
+	s.push(&AToken{Line: s.Line, Column: s.Column, Value: string(raw), Decoded: string(decoded), Type: STRING})
+}
+
+//judge_char lexes a 'c' CHAR literal, starting at the opening "'". It
+//supports the same backslash escapes as judge_string and fills in Value/
+//Decoded the same way, but the body must decode to exactly one rune --
+//empty ('') or multi-rune ('ab') literals are reported as a lex error.
+func judge_char(index *int, source *[]rune, s *ATokenList) {
+	raw := []rune{}
+	decoded := []rune{}
+	(*index)++
+	closed := false
+	for *index < len(*source) {
+		if (*source)[*index] == '\'' {
+			closed = true
+			break
+		}
+
 		if (*source)[*index] == '\\' {
+			raw = append(raw, '\\')
 			(*index)++
-			tmp = append(tmp, (*source)[*index])
+			decoded = append(decoded, decodeEscapeSequence(index, source, s, &raw))
 			(*index)++
 			continue
 		}
-		tmp = append(tmp, (*source)[*index])
+
+		raw = append(raw, (*source)[*index])
+		decoded = append(decoded, (*source)[*index])
 		(*index)++
 	}
-	s.token_list.PushBack(&AToken{s.Line, s.Column, string(tmp), STRING})
+	if !closed {
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "lose a closing \"'\""})
+		(*index)--
+	} else if len(decoded) != 1 {
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "a CHAR literal must contain exactly one character"})
+	}
+	s.push(&AToken{Line: s.Line, Column: s.Column, Value: string(raw), Decoded: string(decoded), Type: CHAR})
 }
 
-func (s *ATokenList) ReadString(data_str string) {
-	data := []rune(data_str)
+//judge_raw_string lexes a `...` raw string literal, starting at the opening
+//backtick. Nothing inside is escaped -- a backslash is just a backslash --
+//and the literal may span multiple lines, so Value and Decoded are always
+//identical. It calls s.advance for every rune it steps over (including the
+//opening and closing backticks), so the caller must not also account for
+//them -- that double bookkeeping is exactly what used to leave Column wrong
+//after a raw string that crossed a line.
+func judge_raw_string(index *int, source *[]rune, s *ATokenList) {
+	s.advance('`')
+	start := *index + 1
+	(*index)++
+	for *index < len(*source) && (*source)[*index] != '`' {
+		s.advance((*source)[*index])
+		(*index)++
+	}
+	if *index >= len(*source) {
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "unterminated raw string, expecting closing '`'"})
+		value := string((*source)[start:*index])
+		s.push(&AToken{Line: s.Line, Column: s.Column, Value: value, Decoded: value, Type: STRING})
+		(*index)--
+		return
+	}
+	s.advance('`')
+	value := string((*source)[start:*index])
+	s.push(&AToken{Line: s.Line, Column: s.Column, Value: value, Decoded: value, Type: STRING})
+}
 
-	i := 0
+//judge_heredoc lexes a <<<LBL ... LBL heredoc body, starting right after the
+//opening "<<<". It reads the label, skips to the start of the next line, and
+//then scans line by line for a line that begins with that exact label -- the
+//label is only recognized at column 0, never mid-line. The newline
+//immediately preceding the closing label is not part of the value (it is the
+//separator between the heredoc body and the terminator, not body content).
+//Interpolation ($name / ${expr}) is left to the parser, which sees the raw
+//STRING token produced here.
+//
+//Every rune it steps over -- label, skipped-to-newline filler, body lines,
+//and the closing label itself -- goes through s.advance, so Column comes
+//out right even though the body can run across many source lines.
+func judge_heredoc(index *int, source *[]rune, s *ATokenList) {
+	labelStart := *index
+	for *index < len(*source) && judge_symbol((*source)[*index]) {
+		s.advance((*source)[*index])
+		(*index)++
+	}
+	label := string((*source)[labelStart:*index])
+	if label == "" {
+		s.Error_list.PushBack(&ALexError{s.Line, s.Column, "heredoc is missing a label after '<<<'"})
+		(*index)--
+		return
+	}
 
-	for {
-		if i >= len(data) {
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "End", EOF})
-			break
-		}
+	for *index < len(*source) && (*source)[*index] != '\n' {
+		s.advance((*source)[*index])
+		(*index)++
+	}
+	if *index < len(*source) {
+		s.advance((*source)[*index])
+		(*index)++
+	}
 
-		tmp_index := i
-		switch data[i] {
-		case 'f': //func
-			if judge_word("func", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "func", FUNC})
-			} else {
-				if judge_word("for", &i, &data) {
-					s.token_list.PushBack(&AToken{s.Line, s.Column, "for", FOR})
-				} else if judge_word("false", &i, &data) {
-					s.token_list.PushBack(&AToken{s.Line, s.Column, "false", FALSE})
-				} else {
-					judge_other(&i, &data, s)
-					break
+	bodyStart := *index
+	for {
+		lineStart := *index
+		labelEnd := lineStart + len(label)
+		if labelEnd <= len(*source) &&
+			string((*source)[lineStart:labelEnd]) == label &&
+			(labelEnd >= len(*source) || !judge_symbol((*source)[labelEnd])) {
+
+			body := (*source)[bodyStart:lineStart]
+			if n := len(body); n > 0 && body[n-1] == '\n' {
+				body = body[:n-1]
+				if n := len(body); n > 0 && body[n-1] == '\r' {
+					body = body[:n-1]
 				}
 			}
-			break
-		case 't': //func
-			if judge_word("true", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "true", TRUE})
-			} else {
-				judge_other(&i, &data, s)
-				break
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: string(body), Decoded: string(body), Type: STRING})
+			for k := lineStart; k < labelEnd; k++ {
+				s.advance((*source)[k])
 			}
-			break
-		case 'r': //func
-			if judge_word("return", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "return", RETURN})
-			} else {
-				judge_other(&i, &data, s)
-				break
-			}
-			break
-		case 'i': //if
-			if judge_word("if", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "if", IF})
-				fmt.Println("fuck:", data[i])
-			}  else {
-				judge_other(&i, &data, s)
-				break
-			}
-			break
+			*index = labelEnd - 1
+			return
+		}
 
-		case 'e': //else
-			if judge_word("elseif", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "elseif", ELSEIF})
-			} else {
-				if judge_word("else", &i, &data) {
-					s.token_list.PushBack(&AToken{s.Line, s.Column, "else", ELSE})
-					break
-				}
-				judge_other(&i, &data, s)
-				break
-			}
-			break
+		if lineStart >= len(*source) {
+			s.Error_list.PushBack(&ALexError{s.Line, s.Column, "unterminated heredoc, expecting closing label '" + label + "'"})
+			*index = lineStart - 1
+			return
+		}
 
-		case 'n': //new
-			if judge_word("new", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "new", NEW})
-			} else {
-				if judge_word("number", &i, &data) {
-					s.token_list.PushBack(&AToken{s.Line, s.Column, "number", NUMBER})
-				} else {
-					judge_other(&i, &data, s)
-					break
-				}
-			}
-			break
+		for *index < len(*source) && (*source)[*index] != '\n' {
+			s.advance((*source)[*index])
+			(*index)++
+		}
+		if *index < len(*source) {
+			s.advance((*source)[*index])
+			(*index)++
+		}
+	}
+}
 
-		case 'l': //list
-			if judge_word("list", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "list", LIST})
-			} else {
-				judge_other(&i, &data, s)
-				break
-			}
-			break
+//judge_line_comment lexes a // line comment, starting right after the "//".
+//It stops just before the line's terminator (\n/\r), leaving that character
+//for ReadString's own '\n'/'\r' cases to push their usual EOF newline token
+//-- a comment ends a line the same way any other line does.
+func judge_line_comment(index *int, source *[]rune, s *ATokenList) {
+	start := *index
+	for *index < len(*source) && (*source)[*index] != '\n' && (*source)[*index] != '\r' {
+		(*index)++
+	}
+	value := string((*source)[start:*index])
+	if s.PreserveComments {
+		s.push(&AToken{Line: s.Line, Column: s.Column, Value: value, Type: LINE_COMMENT})
+	} else {
+		s.addTrivia(TriviaLineComment, "//"+value)
+	}
+	(*index)--
+}
 
-		case 'm': //map
-			if judge_word("map", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "map", MAP})
+//judge_block_comment lexes a /* ... */ block comment, starting right after
+//the opening "/*" (the caller has already advance()d over those two runes).
+//It tracks line/column across embedded newlines itself via s.advance
+//(unlike judge_line_comment, the comment body is consumed whole here, so
+//ReadString's own newline cases never see it) and reports an unterminated
+//comment as a lex error rather than running off the end of the source.
+func judge_block_comment(index *int, source *[]rune, s *ATokenList) {
+	start := *index
+	for {
+		if *index >= len(*source) {
+			s.Error_list.PushBack(&ALexError{s.Line, s.Column, "unterminated block comment, expecting '*/'"})
+			value := string((*source)[start:*index])
+			if s.PreserveComments {
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: value, Type: BLOCK_COMMENT})
 			} else {
-				judge_other(&i, &data, s)
-				break
+				s.addTrivia(TriviaBlockComment, "/*"+value)
 			}
-			break
+			(*index)--
+			return
+		}
 
-		case 'v': //var
-			if judge_word("var", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "var", VAR})
+		if (*source)[*index] == '*' && *index+1 < len(*source) && (*source)[*index+1] == '/' {
+			value := string((*source)[start:*index])
+			if s.PreserveComments {
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: value, Type: BLOCK_COMMENT})
 			} else {
-				judge_other(&i, &data, s)
-				break
+				s.addTrivia(TriviaBlockComment, "/*"+value+"*/")
 			}
-			break
+			s.advance('*')
+			s.advance('/')
+			(*index)++
+			return
+		}
 
-		case 'b': //break
-			if judge_word("break", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "break", BREAK})
-			} else {
-				judge_other(&i, &data, s)
-				break
-			}
-			break
+		if (*source)[*index] == '\r' && *index+1 < len(*source) && (*source)[*index+1] == '\n' {
+			(*index)++
+		}
+		s.advance((*source)[*index])
+		(*index)++
+	}
+}
 
-		case 's':
-			if judge_word("switch", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "switch", SWITCH})
-			} else {
-				judge_other(&i, &data, s)
-				break
-			}
+//ReadString lexes a full source string in one call. It is the entry point
+//every caller used before ReadReader existed, and still the right choice
+//whenever the whole source is already sitting in memory (e.g. a file read
+//with os.ReadFile).
+func (s *ATokenList) ReadString(data_str string) {
+	s.readRunes([]rune(data_str))
+}
+
+//ReadReader lexes source pulled from an io.Reader instead of a pre-loaded
+//string -- the REPL/interactive case, where the caller has a stdin (or
+//socket) stream rather than a complete string up front. It reads that
+//stream one rune at a time off a bufio.Reader, so callers don't have to
+//buffer the whole input themselves before lexing can start; readRunes
+//still needs the full rune slice in hand once reading is done, since
+//judge_heredoc and friends look arbitrarily far ahead for a closing label.
+func (s *ATokenList) ReadReader(r io.Reader) error {
+	br := bufio.NewReader(r)
+	var data []rune
+	for {
+		ch, _, err := br.ReadRune()
+		if err == io.EOF {
 			break
+		}
+		if err != nil {
+			return err
+		}
+		data = append(data, ch)
+	}
+	s.readRunes(data)
+	return nil
+}
 
-		case 'c': //char
-			/*if judge_word("char", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "char", CHAR})
-			} else*/ if judge_word("case", &i, &data) {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "case", CASE})
-			} else if judge_word("continue",&i,&data){
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "continue",CONTINUE})
-			}else{
-				judge_other(&i, &data, s)
-				break
-			}
+func (s *ATokenList) readRunes(data []rune) {
+	i := 0
+
+	for {
+		if i >= len(data) {
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "End", Type: EOF})
 			break
+		}
 
+		tmp_index := i
+		switch data[i] {
 		case ';':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, ";", SEMICOLON})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: ";", Type: SEMICOLON})
 			break
 
 		case '{':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "{", LBRACE})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "{", Type: LBRACE})
 
 			break
 
 		case '}':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "}", RBRACE})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "}", Type: RBRACE})
 
 			break
 
 		case '[':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "[", LBRACKET})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "[", Type: LBRACKET})
 			break
 
 		case ']':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "[", RBRACKET})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "[", Type: RBRACKET})
 			break
 
 		case '(':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "(", LP})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "(", Type: LP})
 			break
 
 		case ')':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, ")", RP})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: ")", Type: RP})
 			break
 
 		case ',':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, ",", COMMA})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: ",", Type: COMMA})
 			break
 
 		case ':':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, ":", COLON})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: ":", Type: COLON})
+			break
+
+		case '?':
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "?", Type: QUESTION})
 			break
 
 		case '+': //+ | ++ | +=
@@ -323,11 +709,11 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '+' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "++", ADDSELF})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "++", Type: ADDSELF})
 			} else if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "+=", COMPOSITE_ADD})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "+=", Type: COMPOSITE_ADD})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "+", ADD})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "+", Type: ADD})
 				i--
 			}
 			break
@@ -338,13 +724,13 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '-' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "--", SUBSELF})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "--", Type: SUBSELF})
 			} else if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "-=", COMPOSITE_SUB})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "-=", Type: COMPOSITE_SUB})
 			} else if data[i] == '>' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "->", CASTING})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "->", Type: CASTING})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "-", SUB})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "-", Type: SUB})
 				i--
 			}
 			break
@@ -355,44 +741,54 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "*=", COMPOSITE_MUL})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "*=", Type: COMPOSITE_MUL})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "*", MUL})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "*", Type: MUL})
 				i--
 			}
 			break
 
-		case '/': // / | /=
+		case '/': // / | /= | // | /*
 			i++
 			if i >= len(data) {
 				break
 			}
 			if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "/=", COMPOSITE_DIV})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "/=", Type: COMPOSITE_DIV})
+			} else if data[i] == '/' {
+				i++
+				judge_line_comment(&i, &data, s)
+			} else if data[i] == '*' {
+				s.advance('/')
+				s.advance('*')
+				i++
+				judge_block_comment(&i, &data, s)
+				tmp_index = i + 1 // judge_block_comment already advance()d its own span
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "/", DIV})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "/", Type: DIV})
 				i--
 			}
 			break
 
 		case '^': // pow (^)
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "^", POWER})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "^", Type: POWER})
 			break
 
 		case '!': // ! | !=
+			i++
 			if i >= len(data) {
 				break
 			}
 			if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "!=", NOEQ})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "!=", Type: NOEQ})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "!", NOT})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "!", Type: NOT})
 				i--
 			}
 			break
 
 		case '.': //.
-			s.token_list.PushBack(&AToken{s.Line, s.Column, ".", QUOTE})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: ".", Type: QUOTE})
 			break
 
 		case '>': //> | >=
@@ -401,22 +797,31 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, ">=", GTEQ})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: ">=", Type: GTEQ})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, ">", GT})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: ">", Type: GT})
 				i--
 			}
 			break
 
-		case '<': //< | <=
+		case '<': //< | <= | <<<LBL heredoc
+			if i+2 < len(data) && data[i+1] == '<' && data[i+2] == '<' {
+				s.advance('<')
+				s.advance('<')
+				s.advance('<')
+				i += 3
+				judge_heredoc(&i, &data, s)
+				tmp_index = i + 1 // judge_heredoc already advance()d its own span
+				break
+			}
 			i++
 			if i >= len(data) {
 				break
 			}
 			if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "<=", LTEQ})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "<=", Type: LTEQ})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "<", LT})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "<", Type: LT})
 				i--
 			}
 			break
@@ -427,9 +832,9 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '=' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "==", EQ})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "==", Type: EQ})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "=", ASSIGMENT})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "=", Type: ASSIGMENT})
 				i--
 			}
 			break
@@ -440,9 +845,9 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '|' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "||", PERHAPS})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "||", Type: PERHAPS})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "|", OR})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "|", Type: OR})
 				i--
 			}
 			break
@@ -453,55 +858,50 @@ func (s *ATokenList) ReadString(data_str string) {
 				break
 			}
 			if data[i] == '&' {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "&&", ALSO})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "&&", Type: ALSO})
 			} else {
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "&", AND})
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "&", Type: AND})
 				i--
 			}
 			break
 
 		case '`':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "`", ESCAPE})
+			judge_raw_string(&i, &data, s)
+			tmp_index = i + 1 // judge_raw_string already advance()d its own span
 			break
 
 		case '%': //mod
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "%", MOD})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "%", Type: MOD})
 			break
 
 		case '~':
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "~", RAND})
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "~", Type: RAND})
 			break
 
 		case '\t':
-			judge_space(&i, &data)
+			judge_space(&i, &data, s)
 			break
 		case ' ':
-			judge_space(&i, &data)
+			judge_space(&i, &data, s)
 			break
 
 		case '\n':
-			tmp_index = i
-			s.token_list.PushBack(&AToken{s.Line, s.Column, "\\n", EOF})
-			s.Line++
-			s.Column = 0
-			break
+			s.push(&AToken{Line: s.Line, Column: s.Column, Value: "\\n", Type: EOF})
+			break // the generic advance() loop below sees data[i]=='\n' and resets Line/Column
 
-		case '\r':
+		case '\r': //\r | \r\n -- either is one line break, never two
 			i++
 			if i >= len(data) {
 				break
 			}
 			if data[i] == '\n' {
-
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "\\r\\b", EOF})
-				s.Line++
-				s.Column = 0
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "\\r\\b", Type: EOF})
+				tmp_index = i // let the generic loop advance() just the '\n'
 			} else {
-
-				s.token_list.PushBack(&AToken{s.Line, s.Column, "\\r", EOF})
-				s.Line++
+				s.push(&AToken{Line: s.Line, Column: s.Column, Value: "\\r", Type: EOF})
 				i--
-				s.Column = 0
+				s.advance('\n') // a lone '\r' is still a line break
+				tmp_index = i + 1
 			}
 			break
 
@@ -509,35 +909,44 @@ func (s *ATokenList) ReadString(data_str string) {
 			judge_string(&i, &data, s)
 			break
 
+		case '\'':
+			judge_char(&i, &data, s)
+			break
+
 		default:
 			if data[i] >= '0' && data[i] <= '9' {
 				judge_number(&i, &data, s)
 			} else {
 				if judge_symbol(data[i]) {
-					judge_other(&i, &data, s)
+					judge_identifier(&i, &data, s)
 				} else {
 					s.Error_list.PushBack(&ALexError{s.Line, s.Column, "illegal token '" + string(data[i]) + "'"})
 				}
 			}
 			break
 		}
-		s.Column += i - tmp_index + 1
+		// i can end up one past the last rune when a multi-rune case (e.g.
+		// judge_identifier, judge_number) runs right up against EOF without
+		// a following non-symbol char to stop on -- clamp so this never
+		// indexes past the end of data.
+		end := i
+		if end >= len(data) {
+			end = len(data) - 1
+		}
+		for k := tmp_index; k <= end; k++ {
+			s.advance(data[k])
+		}
 		i++
 	}
 
-	if s.Error_list.Len() > 0 {
-		fmt.Println("-----------Lex Error------------")
-		for index := s.Error_list.Front(); index != nil; index = index.Next() {
-			fmt.Printf(index.Value.(*ALexError).Error())
-		}
-		fmt.Println("--------------------------------")
-		os.Exit(1)
-	}
-	s.current_iterator = s.token_list.Front()
+	//Error_list累积着，不在这里中止进程——哪怕源码里混着非法token/没闭合的
+	//字符串，调用方(AParser.ReadString/CheckUp)也应该能继续解析并拿到完整的
+	//诊断列表，而不是在第一个词法错误上就把整个程序杀掉
+	s.pos = 0
 }
 
 func (s *ATokenList) ShowAllToken() {
-	for index := s.token_list.Front(); index != nil; index = index.Next() {
+	for range s.tokens {
 		value := s.GetToken()
 		if value.Value == "\r" || value.Value == "\r\n" || value.Value == "\n" {
 			fmt.Println("[Line:", value.Line, ",Column:", value.Column, "] Value= \\n", " Type=", value.Type)
@@ -554,7 +963,6 @@ func (s *ATokenList) ShowAllToken() {
 	}
 }
 func (s *ATokenList) Init() *ATokenList {
-	s.token_list = list.New()
 	s.Error_list = list.New()
 	s.Column = 1
 	s.Line = 1
@@ -562,50 +970,107 @@ func (s *ATokenList) Init() *ATokenList {
 	return s
 }
 
+//GetToken returns the token the cursor currently points at and advances the
+//cursor past it -- equivalent to Peek(0) followed by a one-token Scan. It
+//returns nil once the cursor has moved past the last token (the EOF "End"
+//sentinel included), instead of the old list-based version's habit of
+//oscillating between nil and the sentinel on repeated calls past the end.
 func (s *ATokenList) GetToken() *AToken {
-	//fmt.Println("Current_Token:[Type:",s.current_iterator.Value.(*AToken).Type,"]","[Value:",s.current_iterator.Value.(*AToken).Value,"]")
-
-	//	if(s.current_iterator == s.token_list.Back()){
-	//		s.current_iterator = s.token_list.Back().Prev()
-	//	}
-	var tmp *AToken
-	if s.current_iterator == nil {
-		s.current_iterator = s.token_list.Back()
-	} else {
-		tmp = s.current_iterator.Value.(*AToken)
-		s.current_iterator = s.current_iterator.Next()
+	tok := s.Peek(0)
+	if s.pos < len(s.tokens) {
+		s.pos++
 	}
-
 	s.Count++
-	return tmp
+	return tok
 }
 
-func (s *ATokenList) IsEnd() bool {
-	if s.current_iterator.Next() == s.token_list.Back() {
-		return true
-	}
+//Scan advances to and returns the next token, mirroring the Scan()/Next()
+//shape goyacc-style lexers expect. It is a thin wrapper over GetToken for
+//now -- ReadString still materializes every token up front -- but it gives
+//callers a name to depend on that won't change if the token list grows a
+//real incremental scanner later.
+func (s *ATokenList) Scan() *AToken {
+	return s.GetToken()
+}
 
-	if s.token_list.Len() <= 3 {
-		return true
-	}
+//Tokens returns a channel that yields every token pushed by ReadString/
+//ReadReader, in order, and closes once the final EOF("End") sentinel has
+//been sent -- the goroutine-fed `tokchan` shape from Rob Pike's "Lexical
+//Scanning in Go", for a caller (a REPL loop, a goroutine-based parser) that
+//would rather `for tok := range s.Tokens()` than poll GetToken/IsEnd
+//itself. ReadString or ReadReader must have already run, since Tokens just
+//drains the token list they built.
+func (s *ATokenList) Tokens() <-chan *AToken {
+	ch := make(chan *AToken)
+	go func() {
+		defer close(ch)
+		for {
+			tok := s.GetToken()
+			if tok == nil {
+				return
+			}
+			ch <- tok
+			if tok.Type == EOF && tok.Value == "End" {
+				return
+			}
+		}
+	}()
+	return ch
+}
 
-	//	value := s.current_iterator.Value
-	//	if value == "\r\n" || value == "\n" || value == "\r" {
-	//		fmt.Println("Current_Token:[Type:", s.current_iterator.Value.(*AToken).Type, "]", "[Value:", "\n", "]")
-	//	} else {
-	//		fmt.Println("Current_Token:[Type:", s.current_iterator.Value.(*AToken).Type, "]", "[Value:", s.current_iterator.Value.(*AToken).Value, "]")
-	//	}
+//Peek looks n tokens ahead of the cursor without moving it -- Peek(0) is
+//the token GetToken would return next, Peek(1) the one after that, and so
+//on. It returns nil for an out-of-range n (negative, or past the last
+//token) instead of panicking, which is what made the old IsEnd/BackToken
+//pair unsafe to call at either edge of the token list.
+func (s *ATokenList) Peek(n int) *AToken {
+	idx := s.pos + n
+	if idx < 0 || idx >= len(s.tokens) {
+		return nil
+	}
+	return s.tokens[idx]
+}
 
-	return false
+//IsEnd reports whether the cursor is sitting on the EOF "End" sentinel
+//ReadString/ReadReader always push as the last token -- i.e. there is
+//nothing left worth calling GetToken for.
+func (s *ATokenList) IsEnd() bool {
+	tok := s.Peek(0)
+	return tok == nil || (tok.Type == EOF && tok.Value == "End")
 }
 
+//BackToken moves the cursor back one token and returns the token it now
+//points at -- the undo for a GetToken call, used throughout aparser's
+//one-token lookahead ("peek with GetToken, put it back with BackToken if
+//it wasn't what we wanted"). It clamps at the front of the token list
+//instead of stepping past it, which is what made the old list-iterator
+//version panic when called one time too many.
 func (s *ATokenList) BackToken() *AToken {
-	if s.current_iterator == nil {
-		s.current_iterator = s.token_list.Back().Prev()
-		return s.current_iterator.Value.(*AToken)
+	if s.pos > 0 {
+		s.pos--
+	}
+	return s.Peek(0)
+}
+
+//Mark returns an opaque cursor position a later Restore call can rewind
+//to -- the building block backtracking parsers need for the arbitrary
+//lookahead that disambiguating constructs like a `func` declaration from a
+//call requires.
+func (s *ATokenList) Mark() int {
+	return s.pos
+}
+
+//Restore rewinds the cursor to a position previously returned by Mark. A
+//mark outside [0, len(tokens)] is clamped rather than trusted verbatim, so
+//a stale or hand-rolled mark can't move the cursor out of bounds.
+func (s *ATokenList) Restore(mark int) {
+	if mark < 0 {
+		mark = 0
+	}
+	if mark > len(s.tokens) {
+		mark = len(s.tokens)
 	}
-	s.current_iterator = s.current_iterator.Prev()
-	return s.current_iterator.Value.(*AToken)
+	s.pos = mark
 }
 
 func New() *ATokenList {
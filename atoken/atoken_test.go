@@ -0,0 +1,284 @@
+package atoken
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_StringLiteral_EscapeSequences(t *testing.T) {
+	s := New()
+	s.ReadString(`"a\nb\tc\x41é\$d"`)
+
+	tok := s.GetToken()
+	if tok.Type != STRING {
+		t.Fatalf("expected a STRING token, got %d", tok.Type)
+	}
+	if tok.Value != `a\nb\tc\x41é\$d` {
+		t.Fatalf("expected Value to keep the raw escaped text, got %q", tok.Value)
+	}
+	if tok.Decoded != "a\nb\tcAé$d" {
+		t.Fatalf("expected Decoded to resolve the escapes, got %q", tok.Decoded)
+	}
+}
+
+func Test_StringLiteral_DollarIsLeftRawForInterpolation(t *testing.T) {
+	s := New()
+	s.ReadString(`"hello $name"`)
+
+	tok := s.GetToken()
+	if tok.Value != "hello $name" {
+		t.Fatalf("expected Value to keep an un-escaped '$' intact for aparser's interpolation scan, got %q", tok.Value)
+	}
+	if tok.Decoded != "hello $name" {
+		t.Fatalf("expected Decoded to match Value when there is nothing to escape, got %q", tok.Decoded)
+	}
+}
+
+func Test_CharLiteral(t *testing.T) {
+	s := New()
+	s.ReadString(`'x'`)
+
+	tok := s.GetToken()
+	if tok.Type != CHAR {
+		t.Fatalf("expected a CHAR token, got %d", tok.Type)
+	}
+	if tok.Decoded != "x" {
+		t.Fatalf("expected Decoded 'x', got %q", tok.Decoded)
+	}
+}
+
+func Test_CharLiteral_Escape(t *testing.T) {
+	s := New()
+	s.ReadString(`'\n'`)
+
+	tok := s.GetToken()
+	if tok.Type != CHAR {
+		t.Fatalf("expected a CHAR token, got %d", tok.Type)
+	}
+	if tok.Decoded != "\n" {
+		t.Fatalf("expected Decoded to be a newline, got %q", tok.Decoded)
+	}
+}
+
+func Test_RawString_NoEscapeProcessing(t *testing.T) {
+	s := New()
+	s.ReadString("`raw\\nstring $x`")
+
+	tok := s.GetToken()
+	if tok.Type != STRING {
+		t.Fatalf("expected a STRING token, got %d", tok.Type)
+	}
+	if tok.Value != `raw\nstring $x` {
+		t.Fatalf("expected the backslash to survive un-decoded, got %q", tok.Value)
+	}
+	if tok.Decoded != tok.Value {
+		t.Fatalf("expected Decoded to equal Value for a raw string, got %q vs %q", tok.Decoded, tok.Value)
+	}
+}
+
+func Test_NumberLiteral_IntVsFloat(t *testing.T) {
+	cases := []struct {
+		src      string
+		wantType int
+		wantText string
+	}{
+		{"100\n", INT, "100"},
+		{"3.14\n", NUMBER, "3.14"},
+		{"1e10\n", NUMBER, "1e10"},
+		{"1.5e-3\n", NUMBER, "1.5e-3"},
+		{"2147483648\n", INT64, "2147483648"},
+	}
+	for _, c := range cases {
+		s := New()
+		s.ReadString(c.src)
+		tok := s.GetToken()
+		if tok.Type != c.wantType {
+			t.Fatalf("%q: expected type %s, got %s", c.src, Name(c.wantType), Name(tok.Type))
+		}
+		if tok.Value != c.wantText {
+			t.Fatalf("%q: expected Value %q, got %q", c.src, c.wantText, tok.Value)
+		}
+	}
+}
+
+func Test_ReadReader_MatchesReadString(t *testing.T) {
+	src := "var x = 1 + 2\n"
+
+	s1 := New()
+	s1.ReadString(src)
+
+	s2 := New()
+	if err := s2.ReadReader(strings.NewReader(src)); err != nil {
+		t.Fatalf("ReadReader returned an error: %v", err)
+	}
+
+	for {
+		tok1 := s1.GetToken()
+		tok2 := s2.GetToken()
+		if tok1 == nil || tok2 == nil {
+			if tok1 != tok2 {
+				t.Fatalf("token streams have different lengths")
+			}
+			break
+		}
+		if tok1.Type != tok2.Type || tok1.Value != tok2.Value {
+			t.Fatalf("ReadReader diverged from ReadString: %+v vs %+v", tok1, tok2)
+		}
+	}
+}
+
+func Test_Tokens_ChannelYieldsEveryTokenThenCloses(t *testing.T) {
+	s := New()
+	s.ReadString("x = 1;\n")
+
+	var got []int
+	for tok := range s.Tokens() {
+		got = append(got, tok.Type)
+	}
+
+	if len(got) == 0 || got[len(got)-1] != EOF {
+		t.Fatalf("expected the channel to end with an EOF token, got %v", got)
+	}
+}
+
+func Test_Column_ResetsAfterMultiLineBlockComment(t *testing.T) {
+	s := New()
+	s.ReadString("/*\nfoo\n*/x\n")
+
+	tok := s.GetToken()
+	if tok.Type != WORD || tok.Value != "x" {
+		t.Fatalf("expected the WORD token after the comment, got %+v", tok)
+	}
+	if tok.Line != 3 {
+		t.Fatalf("expected 'x' to be on line 3, got %d", tok.Line)
+	}
+	if tok.Column != 2 {
+		t.Fatalf("expected Column to reflect the position right after the comment's closing '*/', not the comment's whole length, got %d", tok.Column)
+	}
+}
+
+func Test_Peek_LooksAheadWithoutMovingCursor(t *testing.T) {
+	s := New()
+	s.ReadString("1 + 2\n")
+
+	if tok := s.Peek(1); tok.Type != ADD {
+		t.Fatalf("expected Peek(1) to see the '+' token, got %+v", tok)
+	}
+	first := s.GetToken()
+	if first.Type != NUMBER && first.Type != INT {
+		t.Fatalf("expected Peek not to have moved the cursor, GetToken() returned %+v", first)
+	}
+}
+
+func Test_Peek_OutOfRangeReturnsNil(t *testing.T) {
+	s := New()
+	s.ReadString("1\n")
+
+	if tok := s.Peek(-1); tok != nil {
+		t.Fatalf("expected Peek(-1) to return nil, got %+v", tok)
+	}
+	if tok := s.Peek(1000); tok != nil {
+		t.Fatalf("expected an out-of-range Peek to return nil, got %+v", tok)
+	}
+}
+
+func Test_MarkRestore_RewindsCursor(t *testing.T) {
+	s := New()
+	s.ReadString("1 + 2\n")
+
+	mark := s.Mark()
+	s.GetToken()
+	s.GetToken()
+	s.Restore(mark)
+
+	if tok := s.GetToken(); tok.Type != NUMBER && tok.Type != INT {
+		t.Fatalf("expected Restore to rewind back to the first token, got %+v", tok)
+	}
+}
+
+func Test_BackToken_ClampsAtTheFront(t *testing.T) {
+	s := New()
+	s.ReadString("1\n")
+
+	s.BackToken()
+	s.BackToken()
+	if tok := s.GetToken(); tok.Type != NUMBER && tok.Type != INT {
+		t.Fatalf("expected BackToken past the front to clamp there instead of panicking, got %+v", tok)
+	}
+}
+
+func Test_IsEnd_TrueOnlyAtTheEOFSentinel(t *testing.T) {
+	s := New()
+	s.ReadString("1\n")
+
+	for !s.IsEnd() {
+		s.GetToken()
+	}
+	tok := s.Peek(0)
+	if tok == nil || tok.Type != EOF || tok.Value != "End" {
+		t.Fatalf("expected IsEnd to stop exactly at the EOF sentinel, cursor is at %+v", tok)
+	}
+}
+
+func Test_Question_IsLexedAsItsOwnToken(t *testing.T) {
+	s := New()
+	s.ReadString("a?b:c\n")
+
+	tok := s.GetToken() //a
+	if tok.Type != WORD {
+		t.Fatalf("expected a WORD token, got %s", Name(tok.Type))
+	}
+	tok = s.GetToken()
+	if tok.Type != QUESTION || tok.Value != "?" {
+		t.Fatalf("expected a QUESTION token, got %+v", tok)
+	}
+}
+
+func Test_Not_IsLexedWithoutHangingOnTrailingInput(t *testing.T) {
+	s := New()
+	s.ReadString("!(1==1)\n")
+
+	tok := s.GetToken()
+	if tok.Type != NOT || tok.Value != "!" {
+		t.Fatalf("expected a NOT token, got %+v", tok)
+	}
+	tok = s.GetToken()
+	if tok.Type != LP {
+		t.Fatalf("expected '(' right after '!', got %+v", tok)
+	}
+}
+
+func Test_ReadString_LexErrorAccumulatesInsteadOfExiting(t *testing.T) {
+	s := New()
+	s.ReadString(`"bad \q escape"`) // used to os.Exit(1) the whole process here
+
+	if s.Error_list.Len() != 1 {
+		t.Fatalf("expected 1 lex error to accumulate, got %d", s.Error_list.Len())
+	}
+	lexErr := s.Error_list.Front().Value.(*ALexError)
+	if lexErr.Message() != `unknown escape sequence '\q'` {
+		t.Fatalf("unexpected lex error message: %q", lexErr.Message())
+	}
+}
+
+func Test_NumberLiteral_HexOctalBinary(t *testing.T) {
+	cases := []struct {
+		src      string
+		wantText string
+	}{
+		{"0x1A\n", "26"},
+		{"0o17\n", "15"},
+		{"0b1010\n", "10"},
+	}
+	for _, c := range cases {
+		s := New()
+		s.ReadString(c.src)
+		tok := s.GetToken()
+		if tok.Type != INT {
+			t.Fatalf("%q: expected an INT token, got %s", c.src, Name(tok.Type))
+		}
+		if tok.Value != c.wantText {
+			t.Fatalf("%q: expected the decimal equivalent %q, got %q", c.src, c.wantText, tok.Value)
+		}
+	}
+}
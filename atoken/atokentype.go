@@ -1,5 +1,7 @@
 package atoken
 
+import "fmt"
+
 const NUM = 1              //数字
 const WORD = 2             //单词
 const ADD = 3              //加号 +
@@ -60,4 +62,89 @@ const EOF = 57			   //(\n||\r||\r\n)
 const RETURN = 58		   //返回
 const TRUE = 59			   //真
 const FALSE = 60		   //假
-const CONTINUE = 61		   //继续
\ No newline at end of file
+const CONTINUE = 61		   //继续
+const STATIC = 62		   //静态修饰符 static
+const EXTERN = 63		   //外部声明修饰符 extern
+const GHOST = 64		   //幽灵(仅用于验证,不生成代码)修饰符 ghost
+const LINE_COMMENT = 65    //行注释 // ... ，仅在PreserveComments为true时才以token形式出现
+const BLOCK_COMMENT = 66   //块注释 /* ... */，仅在PreserveComments为true时才以token形式出现
+const QUESTION = 67        //三元表达式的问号 cond ? a : b
+
+//typeNames把token常量映射回人类可读的名字，供语法错误信息使用
+var typeNames = map[int]string{
+	NUM:           "NUM",
+	WORD:          "WORD",
+	ADD:           "+",
+	SUB:           "-",
+	MUL:           "*",
+	DIV:           "/",
+	ADDSELF:       "++",
+	SUBSELF:       "--",
+	POWER:         "^",
+	NOT:           "!",
+	CASTING:       "->",
+	QUOTE:         ".",
+	GT:            ">",
+	LT:            "<",
+	GTEQ:          ">=",
+	LTEQ:          "<=",
+	EQ:            "==",
+	NOEQ:          "!=",
+	AND:           "&",
+	OR:            "|",
+	ALSO:          "&&",
+	PERHAPS:       "||",
+	ESCAPE:        "`",
+	COMPOSITE_ADD: "+=",
+	COMPOSITE_SUB: "-=",
+	COMPOSITE_MUL: "*=",
+	COMPOSITE_DIV: "/=",
+	MOD:           "%",
+	RAND:          "~",
+	FUNC:          "func",
+	IF:            "if",
+	ELSE:          "else",
+	NEW:           "new",
+	LBRACE:        "{",
+	RBRACE:        "}",
+	LBRACKET:      "[",
+	RBRACKET:      "]",
+	LP:            "(",
+	RP:            ")",
+	NUMBER:        "number",
+	INT:           "int",
+	INT64:         "int64",
+	CHAR:          "char",
+	STRING:        "string",
+	LIST:          "list",
+	MAP:           "map",
+	VAR:           "var",
+	FOR:           "for",
+	BREAK:         "break",
+	COMMA:         ",",
+	COLON:         ":",
+	SWITCH:        "switch",
+	CASE:          "case",
+	ELSEIF:        "elseif",
+	SEMICOLON:     ";",
+	ASSIGMENT:     "=",
+	EOF:           "EOF",
+	RETURN:        "return",
+	TRUE:          "true",
+	FALSE:         "false",
+	CONTINUE:      "continue",
+	STATIC:        "static",
+	EXTERN:        "extern",
+	GHOST:         "ghost",
+	LINE_COMMENT:  "LINE_COMMENT",
+	BLOCK_COMMENT: "BLOCK_COMMENT",
+	QUESTION:      "?",
+}
+
+//Name返回token类型对应的可读名字，未知类型原样返回数字
+func Name(tokenType int) string {
+	if name, ok := typeNames[tokenType]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", tokenType)
+}
\ No newline at end of file
@@ -0,0 +1,44 @@
+package avm
+
+import (
+	"aparser"
+	"fmt"
+	"strings"
+)
+
+// Disassembler is the second aparser.CodeEmitter implementation: instead of
+// producing executable Instructions, it renders each call as one
+// human-readable line, for inspecting what Emit's post-order walk actually
+// produced.
+type Disassembler struct {
+	lines []string
+}
+
+func (d *Disassembler) EmitConst(v *aparser.AST_Number) {
+	d.lines = append(d.lines, fmt.Sprintf("CONST %s", v.RatString()))
+}
+
+func (d *Disassembler) EmitBinOp(op int) {
+	d.lines = append(d.lines, fmt.Sprintf("BINOP %d", op))
+}
+
+func (d *Disassembler) EmitCall(name string, argc int) {
+	d.lines = append(d.lines, fmt.Sprintf("CALL %s, %d", name, argc))
+}
+
+func (d *Disassembler) EmitLoad(name string) {
+	d.lines = append(d.lines, fmt.Sprintf("LOAD %s", name))
+}
+
+func (d *Disassembler) EmitJumpIfFalse(label string) {
+	d.lines = append(d.lines, fmt.Sprintf("JUMPIFFALSE %s", label))
+}
+
+func (d *Disassembler) EmitLabel(label string) {
+	d.lines = append(d.lines, fmt.Sprintf("LABEL %s", label))
+}
+
+// String renders every instruction emitted so far, one per line.
+func (d *Disassembler) String() string {
+	return strings.Join(d.lines, "\n")
+}
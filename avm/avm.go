@@ -0,0 +1,135 @@
+// Package avm is the first real backend target for aparser's
+// (*AST_Arithmetic_Expression).Emit: a stack-machine bytecode format plus a
+// VM that interprets it against a variable environment. It only covers the
+// pure-expression subset Emit currently produces -- there is no function
+// table or jump target resolution here yet, just enough to turn CONST/LOAD/
+// BINOP into a value.
+package avm
+
+import (
+	"aparser"
+	"math/big"
+)
+
+// OpCode identifies one stack-machine instruction.
+type OpCode int
+
+const (
+	OpConst OpCode = iota
+	OpBinOp
+	OpCall
+	OpLoad
+	OpJumpIfFalse
+	OpLabel
+)
+
+// Instruction is a single bytecode instruction. Only the fields relevant to
+// Op are populated; the rest are left zero.
+type Instruction struct {
+	Op    OpCode
+	Const *aparser.AST_Number
+	BinOp int
+	Name  string
+	Argc  int
+	Label string
+}
+
+// BytecodeEmitter implements aparser.CodeEmitter by appending every call to
+// an Instructions slice, in the order Emit produced them.
+type BytecodeEmitter struct {
+	Instructions []Instruction
+}
+
+func (e *BytecodeEmitter) EmitConst(v *aparser.AST_Number) {
+	e.Instructions = append(e.Instructions, Instruction{Op: OpConst, Const: v})
+}
+
+func (e *BytecodeEmitter) EmitBinOp(op int) {
+	e.Instructions = append(e.Instructions, Instruction{Op: OpBinOp, BinOp: op})
+}
+
+func (e *BytecodeEmitter) EmitCall(name string, argc int) {
+	e.Instructions = append(e.Instructions, Instruction{Op: OpCall, Name: name, Argc: argc})
+}
+
+func (e *BytecodeEmitter) EmitLoad(name string) {
+	e.Instructions = append(e.Instructions, Instruction{Op: OpLoad, Name: name})
+}
+
+func (e *BytecodeEmitter) EmitJumpIfFalse(label string) {
+	e.Instructions = append(e.Instructions, Instruction{Op: OpJumpIfFalse, Label: label})
+}
+
+func (e *BytecodeEmitter) EmitLabel(label string) {
+	e.Instructions = append(e.Instructions, Instruction{Op: OpLabel, Label: label})
+}
+
+// VM interprets a slice of Instruction against an environment of named
+// AST_Number values, using an operand stack.
+type VM struct {
+	Env   map[string]*aparser.AST_Number
+	stack []*aparser.AST_Number
+}
+
+// NewVM creates a VM bound to env. A nil env is fine as long as the program
+// never does an EmitLoad.
+func NewVM(env map[string]*aparser.AST_Number) *VM {
+	return &VM{Env: env}
+}
+
+func (vm *VM) push(v *aparser.AST_Number) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() *aparser.AST_Number {
+	top := len(vm.stack) - 1
+	v := vm.stack[top]
+	vm.stack = vm.stack[:top]
+	return v
+}
+
+// Run executes instructions and returns whatever is left on top of the
+// stack, or nil if the program left nothing behind. OpCall/OpJumpIfFalse/
+// OpLabel don't have anywhere to dispatch to yet -- there's no function
+// table or program counter branching -- so they're accepted but ignored
+// until statement-level Emit exists.
+func (vm *VM) Run(instructions []Instruction) *aparser.AST_Number {
+	for _, instr := range instructions {
+		switch instr.Op {
+		case OpConst:
+			vm.push(instr.Const)
+		case OpLoad:
+			vm.push(vm.Env[instr.Name])
+		case OpBinOp:
+			right := vm.pop()
+			left := vm.pop()
+			vm.push(applyBinOp(instr.BinOp, left, right))
+		case OpCall, OpJumpIfFalse, OpLabel:
+			// no function table / branch targets yet
+		}
+	}
+
+	if len(vm.stack) == 0 {
+		return nil
+	}
+	return vm.pop()
+}
+
+func applyBinOp(op int, left, right *aparser.AST_Number) *aparser.AST_Number {
+	result := &aparser.AST_Number{Rat: new(big.Rat)}
+	switch op {
+	case aparser.ADD:
+		result.Add(left, right)
+	case aparser.SUB:
+		result.Sub(left, right)
+	case aparser.MUL:
+		result.Mul(left, right)
+	case aparser.DIV:
+		result.Div(left, right)
+	case aparser.MOD:
+		result.Mod(left, right)
+	case aparser.POWER:
+		result.Pow(left, right)
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package avm
+
+import (
+	"aparser"
+	"testing"
+)
+
+func Test_BytecodeEmitter_PostOrderEvaluatesCorrectly(t *testing.T) {
+	parser := aparser.New()
+	exp := parser.ReadBasicExpression(`1+2*3`)
+
+	emitter := new(BytecodeEmitter)
+	exp.Emit(emitter)
+
+	vm := NewVM(nil)
+	result := vm.Run(emitter.Instructions)
+	if result.RatString() != "7" {
+		t.Fatalf("expected 1+2*3 to evaluate to 7, got %s", result.RatString())
+	}
+}
+
+func Test_VM_ResolvesVariablesFromEnv(t *testing.T) {
+	parser := aparser.New()
+	exp := parser.ReadBasicExpression(`x+1`)
+
+	emitter := new(BytecodeEmitter)
+	exp.Emit(emitter)
+
+	env := map[string]*aparser.AST_Number{"x": aparser.New_ASTNumber("4")}
+	result := NewVM(env).Run(emitter.Instructions)
+	if result.RatString() != "5" {
+		t.Fatalf("expected x+1 with x=4 to be 5, got %s", result.RatString())
+	}
+}
+
+func Test_Disassembler_RendersOneLinePerInstruction(t *testing.T) {
+	parser := aparser.New()
+	exp := parser.ReadBasicExpression(`1+2`)
+
+	d := new(Disassembler)
+	exp.Emit(d)
+
+	want := "CONST 1\nCONST 2\nBINOP 8" // aparser.ADD == 8
+	if got := d.String(); got != want {
+		t.Fatalf("unexpected disassembly:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func Test_Disassembler_RendersCallInsideArithmeticExpression(t *testing.T) {
+	parser := aparser.New()
+	exp := parser.ReadBasicExpression(`1+foo(2,3)`)
+
+	d := new(Disassembler)
+	exp.Emit(d)
+
+	want := "CONST 1\nCONST 2\nCONST 3\nCALL foo, 2\nBINOP 8" // aparser.ADD == 8
+	if got := d.String(); got != want {
+		t.Fatalf("unexpected disassembly:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func Test_Disassembler_HonorsCheckTypesOverloadRewrite(t *testing.T) {
+	parser := aparser.New()
+	parser.Operators.RegisterOperator(aparser.ADD, aparser.BOOL, aparser.BOOL, aparser.BOOL, &aparser.AST_CallFuncStatement{Name: "bool_or"})
+	exp := parser.ReadBasicExpression(`true+false`)
+	exp.CheckType() // rewrites exp into a call to bool_or
+
+	d := new(Disassembler)
+	exp.Emit(d)
+
+	want := "CONST 1\nCONST 0\nCALL bool_or, 2"
+	if got := d.String(); got != want {
+		t.Fatalf("unexpected disassembly:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func Test_BytecodeEmitter_CallInsideExpressionDoesNotPanic(t *testing.T) {
+	parser := aparser.New()
+	exp := parser.ReadBasicExpression(`foo(2,3)`)
+
+	emitter := new(BytecodeEmitter)
+	exp.Emit(emitter) // used to nil-deref: Value_CallFunc was never set by the parser
+
+	if len(emitter.Instructions) != 3 {
+		t.Fatalf("expected 3 instructions (2 args + call), got %d", len(emitter.Instructions))
+	}
+	last := emitter.Instructions[len(emitter.Instructions)-1]
+	if last.Op != OpCall || last.Name != "foo" || last.Argc != 2 {
+		t.Fatalf("expected a CALL foo/2 instruction last, got %+v", last)
+	}
+}